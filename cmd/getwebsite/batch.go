@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/0xblz/getwebsite/internal/fetcher"
+	"github.com/0xblz/getwebsite/internal/loader"
+	"github.com/0xblz/getwebsite/internal/parser"
+	"github.com/0xblz/getwebsite/internal/renderer"
+)
+
+// runBatch exports every URL listed in listPath into dir, one file per
+// article named after its slugified title. Failures are reported to stderr and
+// skipped; the returned exit code is non-zero only if every URL failed, and
+// then it's the first failure's (see exitCode). Once ctx is done the batch
+// stops, without writing the article it was on.
+func runBatch(ctx context.Context, listPath, dir, format string, fetchOpts fetcher.Options, parseOpts parser.Options, mdOpts renderer.MarkdownOptions, keepTracking bool) int {
+	urls, err := readURLList(listPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", listPath, err)
+		return exitUsage
+	}
+	if len(urls) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no URLs in %s\n", listPath)
+		return exitUsage
+	}
+
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", dir, err)
+		return exitWrite
+	}
+
+	ext := ".md"
+	switch format {
+	case "html":
+		ext = ".html"
+	case "plain":
+		ext = ".txt"
+	case "csv":
+		ext = ".csv"
+	}
+
+	for i := range urls {
+		urls[i] = fetcher.NormalizeURL(urls[i])
+	}
+	// One fetcher for the whole batch so connections and rate limits are
+	// shared; --user and --password go to the listed hosts only
+	fetchOpts.Sites = urls
+	f := fetcher.New(fetchOpts)
+	used := make(map[string]bool)
+	failed, status := 0, 0
+	fail := func(code int) {
+		failed++
+		if status == 0 {
+			status = code
+		}
+	}
+
+	for _, url := range urls {
+		article, err := loader.LoadContext(ctx, f, url, parseOpts)
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "Interrupted")
+			return exitInterrupted
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", url, err)
+			fail(exitCode(err))
+			continue
+		}
+		if !keepTracking {
+			parser.StripTrackingParams(article)
+		}
+
+		var out string
+		switch ext {
+		case ".html":
+			out = renderer.RenderHTML(article)
+		case ".txt":
+			out = renderer.RenderPlainText(article)
+		case ".csv":
+			out = renderer.RenderCSV(article)
+			if out == "" {
+				fmt.Fprintf(os.Stderr, "FAIL %s: no tables found\n", url)
+				fail(exitParse)
+				continue
+			}
+		default:
+			out = renderer.RenderMarkdown(article, mdOpts)
+		}
+
+		path := filepath.Join(dir, uniqueSlug(slugify(article.Title), used)+ext)
+		if err := writeExport(path, []byte(out)); err != nil {
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", url, err)
+			fail(exitWrite)
+			continue
+		}
+		info("OK   %s -> %s\n", url, path)
+	}
+
+	info("Exported %d of %d URLs\n", len(urls)-failed, len(urls))
+	if failed == len(urls) {
+		return status
+	}
+	return 0
+}
+
+// readURLList reads one URL per line, skipping blank lines and # comments.
+func readURLList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// slugify turns a title into a lowercase, dash-separated file name.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	// Cut by rune, not byte, so a long title in a non-Latin script still
+	// makes a valid UTF-8 name
+	slug := b.String()
+	if runes := []rune(slug); len(runes) > 80 {
+		slug = strings.TrimRight(string(runes[:80]), "-")
+	}
+	if slug == "" {
+		slug = "article"
+	}
+	return slug
+}
+
+// uniqueSlug appends a counter to slug if an earlier article in the batch
+// already used it.
+func uniqueSlug(slug string, used map[string]bool) string {
+	name := slug
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s-%d", slug, n)
+	}
+	used[name] = true
+	return name
+}
@@ -1,124 +1,843 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/0xblz/getwebsite/internal/config"
 	"github.com/0xblz/getwebsite/internal/fetcher"
+	"github.com/0xblz/getwebsite/internal/loader"
 	"github.com/0xblz/getwebsite/internal/parser"
 	"github.com/0xblz/getwebsite/internal/renderer"
 	"github.com/0xblz/getwebsite/internal/ui"
+	"github.com/alecthomas/chroma/v2/styles"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 	"golang.org/x/term"
 )
 
+const usage = "Usage: getwebsite <url>... [--pipe] [--width N|auto] [--wpm N] [--toc] [--links-only] [--stats] [--dump-html] [--no-color] [--format text|plain|json|html|markdown|csv|svg] [--export FILE] [--front-matter] [--md-wrap N] [--md-anchors] [--ascii-images] [--no-images] [--no-hero] [--no-cache] [--refresh] [--cache-ttl D] [--fresh] [--no-spinner] [--theme NAME] [--list-themes] [--code-style NAME] [--code-line-numbers] [--expand-abbr] [--table-truncate] [--superscript-refs] [--compact] [--renderer default|glamour] [--favicon] [--user-agent UA] [--header \"K: V\"]... [--cookie \"name=value\"]... [--cookies-file FILE] [--user U] [--password P] [--proxy URL] [--rate-limit D] [--max-size N] [--max-redirects N] [--force] [--exclude SEL]... [--keep-tracking] [--quiet]\n       getwebsite --batch FILE [--export-dir DIR] [--format html|markdown|plain|csv] [--front-matter] [--md-wrap N] [--md-anchors] [--rate-limit D] [--exclude SEL]... [--keep-tracking] [--quiet]"
+
+// quiet suppresses informational messages on stderr, leaving only errors.
+var quiet bool
+
+// info prints a progress message to stderr unless --quiet was given.
+func info(format string, args ...any) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// logRedirect reports each redirect hop followed while fetching.
+func logRedirect(from, to string) {
+	info("Redirected to %s\n", to)
+}
+
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: getwebsite <url> [--pipe] [--width N] [--export FILE]")
-		os.Exit(1)
+		fmt.Println(usage)
+		os.Exit(exitUsage)
 	}
 
-	url := os.Args[1]
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	var urls []string
 	pipeMode := false
-	width := 90
+	width := cfg.Width
+	autoWidth := false
 	exportPath := ""
+	format := "text"
+	tocMode := false
+	statsMode := false
+	linksOnly := false
+	noColor := false
+	opts := renderer.Options{NoImages: !cfg.Images}
+	fetchOpts := fetcher.Options{Timeout: cfg.Timeout, UserAgent: cfg.UserAgent}
+	mdOpts := renderer.MarkdownOptions{}
+	parseOpts := parser.Options{}
+	fresh := false
+	cacheOpts := loader.CacheOptions{TTL: loader.DefaultCacheTTL}
+	// Like NO_COLOR, any non-empty value turns the animation off
+	noSpinner := os.Getenv("GETWEBSITE_NO_ANIM") != ""
+	themeName := cfg.Theme
+	opts.CodeStyle = cfg.CodeStyle
+	batchPath := ""
+	exportDir := ""
+	keepTracking := false
+	dumpHTML := false
+	showHelp := false
+	showVersion := false
+	listThemes := false
+
+	// The help text is written out by hand in printHelp, so flags carry no
+	// usage strings of their own
+	fs := flag.NewFlagSet("getwebsite", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+
+	fs.BoolVar(&pipeMode, "pipe", false, "")
+	fs.Func("width", "", func(v string) error {
+		if strings.ToLower(v) == "auto" {
+			autoWidth = true
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("want a positive number or auto")
+		}
+		width, autoWidth = n, false
+		return nil
+	})
+	fs.Func("wpm", "", func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("want a positive number")
+		}
+		opts.WPM = n
+		return nil
+	})
+	fs.StringVar(&exportPath, "export", "", "")
+	fs.BoolVar(&tocMode, "toc", false, "")
+	fs.BoolVar(&statsMode, "stats", false, "")
+	fs.BoolVar(&dumpHTML, "dump-html", false, "")
+	fs.BoolVar(&linksOnly, "links-only", false, "")
+	fs.BoolVar(&noColor, "no-color", false, "")
+	fs.Func("format", "", func(v string) error {
+		format = strings.ToLower(v)
+		return nil
+	})
+	fs.BoolVar(&opts.ASCIIImages, "ascii-images", false, "")
+	fs.BoolVar(&opts.NoImages, "no-images", opts.NoImages, "")
+	fs.BoolVar(&opts.NoCache, "no-cache", false, "")
+	fs.BoolVar(&fresh, "fresh", false, "")
+	fs.BoolVar(&cacheOpts.Refresh, "refresh", false, "")
+	fs.Func("cache-ttl", "", func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("want a duration like 10m, or 0 to turn the cache off")
+		}
+		cacheOpts.TTL = d
+		return nil
+	})
+	fs.BoolVar(&fetchOpts.Force, "force", false, "")
+	fs.BoolVar(&keepTracking, "keep-tracking", false, "")
+	fs.Func("rate-limit", "", func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("want a duration like 2s")
+		}
+		fetchOpts.RateLimit = d
+		return nil
+	})
+	fs.Func("max-size", "", func(v string) error {
+		n, err := fetcher.ParseSize(v)
+		if err != nil {
+			return fmt.Errorf("want a size like 20MB")
+		}
+		fetchOpts.MaxSize = n
+		return nil
+	})
+	fs.Func("max-redirects", "", func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("want a number of redirects, 0 or more")
+		}
+		fetchOpts.MaxRedirects = n
+		if n == 0 {
+			fetchOpts.MaxRedirects = -1 // fetcher treats 0 as the default
+		}
+		return nil
+	})
+	fs.Func("theme", "", func(v string) error {
+		themeName = strings.ToLower(v)
+		return nil
+	})
+	fs.BoolVar(&opts.LineNumbers, "code-line-numbers", false, "")
+	fs.BoolVar(&opts.ExpandAbbr, "expand-abbr", false, "")
+	fs.BoolVar(&opts.TableTruncate, "table-truncate", false, "")
+	fs.BoolVar(&opts.Favicon, "favicon", false, "")
+	fs.BoolVar(&opts.NoHero, "no-hero", false, "")
+	fs.BoolVar(&opts.SuperscriptRefs, "superscript-refs", false, "")
+	fs.BoolVar(&opts.Compact, "compact", false, "")
+	fs.Func("renderer", "", func(v string) error {
+		switch strings.ToLower(v) {
+		case "default":
+			opts.Glamour = false
+		case "glamour":
+			opts.Glamour = true
+		default:
+			return fmt.Errorf("want default or glamour")
+		}
+		return nil
+	})
+	fs.BoolVar(&noSpinner, "no-spinner", noSpinner, "")
+	fs.BoolVar(&mdOpts.FrontMatter, "front-matter", false, "")
+	fs.BoolVar(&mdOpts.Anchors, "md-anchors", false, "")
+	fs.Func("md-wrap", "", func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("want a number of columns, or 0 for no wrapping")
+		}
+		mdOpts.Wrap = n
+		return nil
+	})
+	fs.Func("code-style", "", func(v string) error {
+		opts.CodeStyle = strings.ToLower(v)
+		return nil
+	})
+	fs.StringVar(&fetchOpts.UserAgent, "user-agent", fetchOpts.UserAgent, "")
+	fs.Func("header", "", func(v string) error {
+		key, value, err := parseHeader(v)
+		if err != nil {
+			return err
+		}
+		if fetchOpts.Headers == nil {
+			fetchOpts.Headers = make(http.Header)
+		}
+		fetchOpts.Headers.Add(key, value)
+		return nil
+	})
+	fs.Func("cookie", "", func(v string) error {
+		cookies, err := http.ParseCookie(v)
+		if err != nil {
+			return fmt.Errorf(`want "name=value"`)
+		}
+		fetchOpts.Cookies = append(fetchOpts.Cookies, cookies...)
+		return nil
+	})
+	fs.Func("cookies-file", "", func(v string) error {
+		cookies, err := fetcher.ReadCookiesFile(v)
+		if err != nil {
+			return err
+		}
+		fetchOpts.Cookies = append(fetchOpts.Cookies, cookies...)
+		return nil
+	})
+	fs.StringVar(&fetchOpts.User, "user", "", "")
+	fs.StringVar(&fetchOpts.Password, "password", "", "")
+	fs.Func("proxy", "", func(v string) error {
+		u, err := fetcher.ParseProxy(v)
+		if err != nil {
+			return err
+		}
+		fetchOpts.Proxy = u
+		return nil
+	})
+	fs.Func("exclude", "", func(v string) error {
+		if err := parser.CheckSelector(v); err != nil {
+			return err
+		}
+		parseOpts.Exclude = append(parseOpts.Exclude, v)
+		return nil
+	})
+	fs.StringVar(&batchPath, "batch", "", "")
+	fs.StringVar(&exportDir, "export-dir", "", "")
+	fs.BoolVar(&quiet, "quiet", false, "")
+	fs.BoolVar(&showHelp, "help", false, "")
+	fs.BoolVar(&showVersion, "version", false, "")
+	fs.BoolVar(&listThemes, "list-themes", false, "")
 
-	for i := 2; i < len(os.Args); i++ {
-		switch os.Args[i] {
-		case "--pipe", "-p":
+	for short, long := range map[string]string{
+		"p": "pipe", "w": "width", "e": "export", "t": "toc", "f": "format",
+		"H": "header", "q": "quiet", "h": "help", "v": "version",
+	} {
+		fs.Var(fs.Lookup(long).Value, short, "")
+	}
+
+	// URLs may come before, after or between the flags
+	args := os.Args[1:]
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(exitUsage)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		urls, args = append(urls, args[0]), args[1:]
+	}
+
+	if showHelp {
+		printHelp()
+		return
+	}
+	if showVersion {
+		fmt.Println("getwebsite " + version)
+		return
+	}
+	if listThemes {
+		if noColor {
+			lipgloss.SetColorProfile(termenv.Ascii)
+		}
+		if err := printThemes(themeName, cfg.Colors, width); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		return
+	}
+
+	if opts.NoCache {
+		cacheOpts.TTL = 0
+	}
+
+	if fetchOpts.Password != "" && fetchOpts.User == "" {
+		fmt.Fprintln(os.Stderr, "Error: --password needs --user")
+		os.Exit(exitUsage)
+	}
+
+	if batchPath != "" {
+		switch format {
+		case "text", "markdown", "html", "plain", "csv":
+		default:
+			fmt.Fprintf(os.Stderr, "Error: --batch can't export format %q (want markdown, html, plain or csv)\n", format)
+			os.Exit(exitUsage)
+		}
+		fetchOpts.OnRedirect = logRedirect
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		code := runBatch(ctx, batchPath, exportDir, format, fetchOpts, parseOpts, mdOpts, keepTracking)
+		stop()
+		os.Exit(code)
+	}
+	if len(urls) == 0 {
+		fmt.Println(usage)
+		os.Exit(exitUsage)
+	}
+
+	switch format {
+	case "text":
+	case "json", "html", "markdown", "plain", "csv", "svg":
+		// With --export these pick the file format; otherwise they go to stdout
+		if exportPath == "" {
 			pipeMode = true
-		case "--width", "-w":
-			if i+1 < len(os.Args) {
-				fmt.Sscanf(os.Args[i+1], "%d", &width)
-				i++
-			}
-		case "--export", "-e":
-			if i+1 < len(os.Args) {
-				exportPath = os.Args[i+1]
-				i++
-			}
 		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (want text, plain, json, html, markdown, csv or svg)\n", format)
+		os.Exit(exitUsage)
 	}
 
-	// Detect if stdout is not a terminal (piping)
-	if !term.IsTerminal(int(os.Stdout.Fd())) {
+	if tocMode || linksOnly || statsMode || (dumpHTML && exportPath == "") {
 		pipeMode = true
 	}
 
-	url = fetcher.NormalizeURL(url)
+	// An SVG keeps the colors however the output is detected, since it's
+	// drawn from the terminal rendering rather than shown on a terminal
+	svgMode := format == "svg" || (exportPath != "" && exportFormat(exportPath, format) == "svg")
+	if noColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	} else if svgMode {
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	}
 
-	// Export and pipe modes need to fetch + parse here
-	if pipeMode || exportPath != "" {
-		f := fetcher.New()
+	// Detect if stdout is not a terminal (piping)
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		pipeMode = true
+	}
 
-		fmt.Fprintf(os.Stderr, "Fetching %s...\n", url)
+	if autoWidth {
+		width = terminalWidth(pipeMode)
+	}
 
-		html, err := f.Fetch(url)
+	// Only terminal output and SVGs of it are colored, so only they need a theme
+	if format == "text" || format == "svg" {
+		theme, err := resolveTheme(themeName, cfg.Colors)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
+		opts.Theme = theme
 
-		article, err := parser.Parse(html, url)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error parsing: %v\n", err)
-			os.Exit(1)
+		if opts.CodeStyle != "" && !slices.Contains(styles.Names(), opts.CodeStyle) {
+			fmt.Fprintf(os.Stderr, "Warning: unknown code style %q, using %s\n", opts.CodeStyle, styles.Fallback.Name)
+			opts.CodeStyle = styles.Fallback.Name
+		}
+	}
+
+	for i := range urls {
+		urls[i] = fetcher.NormalizeURL(urls[i])
+	}
+	// --user and --password go to these hosts only
+	fetchOpts.Sites = urls
+
+	// renderSVG draws the terminal rendering of an article as an SVG image
+	renderSVG := func(ctx context.Context, article *parser.Article) string {
+		r := renderer.New(width, opts)
+		if !opts.NoImages {
+			r.Images = renderer.FetchArticleImages(ctx, opts.Fetcher, article, opts.NoCache)
+		}
+		return r.RenderSVG(article)
+	}
+
+	// render produces what pipe mode prints for an article
+	render := func(ctx context.Context, article *parser.Article, url string) (string, error) {
+		switch {
+		case dumpHTML:
+			return article.RawHTML + "\n", nil
+		case tocMode:
+			return renderer.New(width, opts).RenderTOC(article), nil
+		case linksOnly:
+			return renderer.New(width, opts).RenderLinkList(article.Links), nil
+		case statsMode:
+			return renderer.New(width, opts).RenderStats(article), nil
+		}
+
+		switch format {
+		case "json":
+			return renderJSON(article, opts.WPM)
+		case "html":
+			return renderer.RenderHTML(article), nil
+		case "markdown":
+			return renderer.RenderMarkdown(article, mdOpts), nil
+		case "plain":
+			return renderer.RenderPlainText(article), nil
+		case "csv":
+			out := renderer.RenderCSV(article)
+			if out == "" {
+				return "", fmt.Errorf("no tables found in %s", url)
+			}
+			return out, nil
+		case "svg":
+			return renderSVG(ctx, article), nil
 		}
+		r := renderer.New(width, opts)
+		if !opts.NoImages {
+			// Download here so Ctrl-C can cut a slow image short
+			r.Images = renderer.FetchArticleImages(ctx, opts.Fetcher, article, opts.NoCache)
+		}
+		return r.RenderArticle(article), nil
+	}
+
+	// Export and pipe modes need to fetch + parse here, one URL after another
+	if pipeMode || exportPath != "" {
+		// Only here: the interactive reader owns the terminal
+		fetchOpts.OnRedirect = logRedirect
+		f := fetcher.New(fetchOpts)
+		// Images come through the same fetcher as the pages
+		opts.Fetcher = f
+		failed, status := 0, 0
+		fail := func(code int) {
+			failed++
+			if status == 0 {
+				status = code
+			}
+		}
+		printed := false
+
+		// Ctrl-C cancels the fetch in flight instead of killing the process
+		// midway through printing or writing an article
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		for i, url := range urls {
+			info("Fetching %s...\n", url)
+
+			article, err := loader.LoadCachedContext(ctx, f, url, parseOpts, cacheOpts)
+			if ctx.Err() != nil {
+				interrupted()
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				fail(exitCode(err))
+				continue
+			}
+			if !keepTracking {
+				parser.StripTrackingParams(article)
+			}
+
+			if exportPath != "" {
+				path := exportPath
+				if len(urls) > 1 {
+					path = numberedPath(exportPath, i+1)
+				}
+				fileFormat := exportFormat(path, format)
+				if dumpHTML {
+					fileFormat = "raw"
+				}
+				var out string
+				switch fileFormat {
+				case "raw":
+					out = article.RawHTML + "\n"
+				case "html":
+					out = renderer.RenderHTML(article)
+				case "plain":
+					out = renderer.RenderPlainText(article)
+				case "svg":
+					out = renderSVG(ctx, article)
+				case "json":
+					out, err = renderJSON(article, opts.WPM)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+						fail(exitError)
+						continue
+					}
+				case "csv":
+					out = renderer.RenderCSV(article)
+					if out == "" {
+						fmt.Fprintf(os.Stderr, "Error: no tables found in %s\n", url)
+						fail(exitParse)
+						continue
+					}
+				default:
+					out = renderer.RenderMarkdown(article, mdOpts)
+				}
+				if ctx.Err() != nil {
+					interrupted()
+				}
+				if err := writeExport(path, []byte(out)); err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+					fail(exitWrite)
+					continue
+				}
+				info("Exported to %s\n", path)
+				if !pipeMode {
+					continue
+				}
+			}
 
-		if exportPath != "" {
-			md := renderer.RenderMarkdown(article)
-			if err := os.WriteFile(exportPath, []byte(md), 0644); err != nil {
-				fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", exportPath, err)
-				os.Exit(1)
+			out, err := render(ctx, article, url)
+			if ctx.Err() != nil {
+				interrupted()
 			}
-			fmt.Fprintf(os.Stderr, "Exported to %s\n", exportPath)
-			if pipeMode {
-				r := renderer.New(width)
-				fmt.Print(r.RenderArticle(article))
+			if err != nil {
+				// The page had nothing this output could show, like a CSV
+				// of a page without tables
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				fail(exitParse)
+				continue
 			}
-			return
+			if printed {
+				fmt.Print(articleDivider(format, width, opts.Theme))
+			}
+			fmt.Print(out)
+			printed = true
 		}
 
-		r := renderer.New(width)
-		fmt.Print(r.RenderArticle(article))
+		if failed > 0 {
+			os.Exit(status)
+		}
 		return
 	}
 
+	if len(urls) > 1 {
+		info("Opening %s (the reader shows one page; use --pipe or --export for all %d)\n", urls[0], len(urls))
+	}
+	url := urls[0]
+
 	// Interactive mode — UI handles fetching with spinner
-	m := ui.New(url)
+	m := ui.New(url, ui.Options{Renderer: opts, Markdown: mdOpts, Fetcher: fetchOpts, Parser: parseOpts, Cache: cacheOpts, Width: width, Fresh: fresh, KeepTracking: keepTracking, NoSpinner: noSpinner})
 	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+	if m, ok := final.(ui.Model); ok && m.Cancelled() {
+		fmt.Fprintln(os.Stderr, "cancelled")
 	}
 }
 
-func init() {
-	if len(os.Args) > 1 {
-		arg := strings.ToLower(os.Args[1])
-		if arg == "--help" || arg == "-h" {
-			fmt.Println("getwebsite - Read websites beautifully in your terminal")
-			fmt.Println()
-			fmt.Println("Usage: getwebsite <url> [options]")
-			fmt.Println()
-			fmt.Println("Options:")
-			fmt.Println("  --pipe, -p       Output plain text (no interactive UI)")
-			fmt.Println("  --width, -w N    Set output width (default: 90)")
-			fmt.Println("  --export, -e F   Export article as markdown to file F")
-			fmt.Println("  --help, -h       Show this help")
-			fmt.Println("  --version, -v    Show version")
+// exportFormat picks the export file format: an explicit --format other than
+// text wins, otherwise it's inferred from the file extension.
+func exportFormat(path, format string) string {
+	if format != "text" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return "html"
+	case ".txt":
+		return "plain"
+	case ".csv":
+		return "csv"
+	case ".svg":
+		return "svg"
+	case ".json":
+		return "json"
+	}
+	return "markdown"
+}
+
+// renderJSON encodes article, with its reading time at wpm words a minute,
+// for --format json.
+func renderJSON(article *parser.Article, wpm int) (string, error) {
+	out, err := json.MarshalIndent(struct {
+		*parser.Article
+		ReadingTime renderer.ReadingTime `json:"reading_time"`
+	}{article, renderer.ArticleStats(article).ReadingTime(wpm)}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding JSON: %v", err)
+	}
+	return string(out) + "\n", nil
+}
+
+// numberedPath inserts n before the extension of path, so exporting several
+// URLs to article.md writes article-1.md, article-2.md and so on.
+func numberedPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// Exit statuses, so scripts can tell a page that's gone from a network
+// hiccup. With several URLs the first failure decides.
+const (
+	exitError       = 1   // anything else, such as an unreadable config file
+	exitUsage       = 2   // bad flags or arguments
+	exitFetch       = 3   // network failure or unusable response
+	exitHTTP        = 4   // the server answered with an error status
+	exitParse       = 5   // no readable content in the page
+	exitWrite       = 6   // an export file couldn't be written
+	exitInterrupted = 130 // Ctrl-C or SIGTERM, by shell convention
+)
+
+// exitCode returns the exit status for an error from loading a page.
+func exitCode(err error) int {
+	var statusErr *fetcher.StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		return exitHTTP
+	case errors.Is(err, parser.ErrNoContent), errors.Is(err, parser.ErrExtract):
+		return exitParse
+	}
+	return exitFetch
+}
+
+// interrupted reports a Ctrl-C or SIGTERM and exits, leaving nothing half
+// printed or half written behind.
+func interrupted() {
+	fmt.Fprintln(os.Stderr, "Interrupted")
+	os.Exit(exitInterrupted)
+}
+
+// writeExport writes data to path through a temporary file in the same
+// directory, so an interrupted or failed write never leaves a truncated file.
+func writeExport(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// articleDivider separates consecutive articles in pipe mode, drawn in the
+// theme's HR color. JSON and HTML documents and CSV tables already stand
+// apart, so they only get a blank line.
+func articleDivider(format string, width int, theme renderer.Theme) string {
+	switch format {
+	case "json", "html", "csv", "svg":
+		return "\n"
+	case "markdown":
+		return "\n---\n\n"
+	case "plain":
+		return "\n" + strings.Repeat("=", width) + "\n\n"
+	}
+	style := lipgloss.NewStyle().Foreground(theme.HR)
+	return "\n" + style.Render(strings.Repeat("━", width)) + "\n\n"
+}
+
+// maxAutoWidth caps --width auto so lines stay readable on huge terminals.
+const maxAutoWidth = 200
+
+// terminalWidth picks the width for --width auto. The interactive UI follows
+// the window as it resizes, so it only needs the cap; pipe and export output
+// is rendered once at the terminal's current width, or 90 if there is no
+// terminal.
+func terminalWidth(pipeMode bool) int {
+	if !pipeMode {
+		return maxAutoWidth
+	}
+	w, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || w <= 0 {
+		return 90
+	}
+	return min(w, maxAutoWidth)
+}
+
+// parseHeader splits a "Key: Value" header flag, rejecting malformed names.
+func parseHeader(h string) (string, string, error) {
+	key, value, ok := strings.Cut(h, ":")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("invalid header %q (want \"Key: Value\")", h)
+	}
+	for _, r := range key {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`()<>@,;:\"/[]?={}`, r) {
+			return "", "", fmt.Errorf("invalid header name %q", key)
+		}
+	}
+	return key, strings.TrimSpace(value), nil
+}
+
+// resolveTheme looks up a preset theme by name, or picks dark or light from
+// the terminal background when name is empty, then applies color overrides.
+func resolveTheme(name string, colors map[string]string) (renderer.Theme, error) {
+	if name == "" {
+		name = "dark"
+		if !termenv.HasDarkBackground() {
+			name = "light"
+		}
+	}
+	theme, ok := renderer.ThemeByName(name)
+	if !ok {
+		return theme, fmt.Errorf("unknown theme %q (want %s)", name, strings.Join(renderer.ThemeNames(), ", "))
+	}
+	for color, value := range colors {
+		if err := theme.SetColor(color, value); err != nil {
+			return theme, fmt.Errorf("in config: %v", err)
+		}
+	}
+	return theme, nil
+}
+
+// printThemes prints a sample of every preset theme for --list-themes, with
+// the config file's color overrides applied as they would be when reading,
+// and marks the theme --theme or the config would pick.
+func printThemes(current string, colors map[string]string, width int) error {
+	chosen, err := resolveTheme(current, colors)
+	if err != nil {
+		return err
+	}
+	nameStyle := lipgloss.NewStyle().Bold(true)
+	for i, name := range renderer.ThemeNames() {
+		theme, err := resolveTheme(name, colors)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
 			fmt.Println()
-			fmt.Println("Examples:")
-			fmt.Println("  getwebsite example.com")
-			fmt.Println("  getwebsite https://news.ycombinator.com --pipe")
-			fmt.Println("  getwebsite blaze.design --width 120")
-			fmt.Println("  getwebsite blaze.design --export article.md")
-			os.Exit(0)
 		}
-		if arg == "--version" || arg == "-v" {
-			fmt.Println("getwebsite v0.1.0")
-			os.Exit(0)
+		label := name
+		if name == chosen.Name {
+			label += " (current)"
 		}
+		fmt.Println(nameStyle.Render(label))
+		fmt.Print(renderer.New(width, renderer.Options{Theme: theme}).RenderThemeSample())
 	}
+	return nil
+}
+
+// version is printed by --version.
+const version = "v0.1.0"
+
+// printHelp prints the full --help text.
+func printHelp() {
+	fmt.Println("getwebsite - Read websites beautifully in your terminal")
+	fmt.Println()
+	fmt.Println("Usage: getwebsite <url>... [options]")
+	fmt.Println()
+	fmt.Println("Several URLs are printed one after another in pipe mode and exported to")
+	fmt.Println("numbered files (article-1.md, article-2.md, ...); the reader opens the first.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --pipe, -p       Output plain text (no interactive UI)")
+	fmt.Println("  --width, -w N    Set output width (default: 90, or width in the config file);")
+	fmt.Println("                   \"auto\" uses the full terminal width")
+	fmt.Println("  --wpm N          Reading speed for the time estimate (default: 238 words/min)")
+	fmt.Println("  --toc, -t        Print only the heading outline")
+	fmt.Println("  --links-only     Print only the article's links, one per line")
+	fmt.Println("  --stats          Print word count, reading time and block counts")
+	fmt.Println("  --dump-html      Print the cleaned HTML the article was parsed from (to the")
+	fmt.Println("                   --export file if one is given), for debugging extraction")
+	fmt.Println("  --no-color       Disable colors and text styling")
+	fmt.Println("  --format, -f F   Output format: text (default), plain, json, html, markdown,")
+	fmt.Println("                   csv (the article's tables only) or svg (an image of the")
+	fmt.Println("                   terminal rendering, --width columns wide)")
+	fmt.Println("  --export, -e F   Export article to file F (.html for HTML, .txt for plain text,")
+	fmt.Println("                   .csv for tables, .svg for an image, .json for JSON, else")
+	fmt.Println("                   markdown)")
+	fmt.Println("  --front-matter   Put title, author, date and URL in YAML front matter (markdown)")
+	fmt.Println("  --md-wrap N      Wrap markdown paragraphs and list items at N columns")
+	fmt.Println("  --md-anchors     End markdown headings with a {#slug} anchor")
+	fmt.Println("  --ascii-images   Render images as ASCII art instead of half-blocks")
+	fmt.Println("  --no-images      Skip fetching and rendering images")
+	fmt.Println("  --no-hero        Don't show the page's og:image under the title")
+	fmt.Println("  --no-cache       Don't read or write the on-disk article and image caches")
+	fmt.Println("  --refresh        Fetch the page again even if a cached copy is current")
+	fmt.Println("  --cache-ttl D    Reuse a page parsed within D, e.g. 1h; 0 turns it off (default: 10m)")
+	fmt.Println("  --fresh          Start at the top instead of the saved reading position")
+	fmt.Println("  --no-spinner     Show a static loading line (also GETWEBSITE_NO_ANIM=1)")
+	fmt.Println("  --theme NAME     Color theme: dark, light, solarized or mono (default: detect)")
+	fmt.Println("  --list-themes    Print a sample of each theme and exit")
+	fmt.Println("  --code-style S   Chroma syntax highlighting style (default: monokai)")
+	fmt.Println("  --code-line-numbers  Number the lines of code blocks")
+	fmt.Println("  --expand-abbr    Spell out abbreviations after their first use")
+	fmt.Println("  --table-truncate Cut long table cells off with … instead of wrapping them")
+	fmt.Println("  --superscript-refs  Mark links with superscript numbers (word³) instead of [3]")
+	fmt.Println("  --compact        Dense layout: no title box or code borders, tighter spacing")
+	fmt.Println("  --renderer R     default, or glamour to render the markdown with glamour")
+	fmt.Println("  --favicon        Show the site's icon before the title (iTerm2 and Kitty)")
+	fmt.Println("  --user-agent UA  Send UA as the User-Agent header")
+	fmt.Println("  --header, -H H   Add a request header \"Key: Value\" (repeatable)")
+	fmt.Println("  --cookie C       Send cookie \"name=value\" to the URL's host (repeatable)")
+	fmt.Println("  --cookies-file F Load cookies from a Netscape cookies.txt file")
+	fmt.Println("  --user U         HTTP Basic auth user for the URL's host (or put user:pass@ in it)")
+	fmt.Println("  --password P     HTTP Basic auth password for --user")
+	fmt.Println("  --proxy URL      Fetch through an http://, https:// or socks5:// proxy")
+	fmt.Println("                   (default: HTTP_PROXY, HTTPS_PROXY or ALL_PROXY)")
+	fmt.Println("  --rate-limit D   Wait at least D (e.g. 2s) between requests to the same host")
+	fmt.Println("  --max-size N     Largest page to download, e.g. 20MB (default: 10MB)")
+	fmt.Println("  --max-redirects N  Follow at most N redirects, 0 for none (default: 10)")
+	fmt.Println("  --force          Parse responses even if they aren't HTML (e.g. a PDF)")
+	fmt.Println("  --exclude SEL    Drop elements matching CSS selector SEL, e.g. \"div.related\" (repeatable)")
+	fmt.Println("  --keep-tracking  Keep utm_*, fbclid and other tracking parameters on links")
+	fmt.Println("  --batch F        Export every URL listed in file F (one per line, # for comments)")
+	fmt.Println("  --export-dir D   Directory for --batch exports (default: current directory)")
+	fmt.Println("  --quiet, -q      Only print errors on stderr, not progress messages")
+	fmt.Println("  --help, -h       Show this help")
+	fmt.Println("  --version, -v    Show version")
+	fmt.Println()
+	fmt.Println("Flags can go before or after the URL, and take values as --flag value or")
+	fmt.Println("--flag=value.")
+	fmt.Println()
+	fmt.Println("Defaults can be set in $XDG_CONFIG_HOME/getwebsite/config.toml")
+	fmt.Println("(width, timeout, images, user_agent, theme, code_style and a [colors] table).")
+	fmt.Println()
+	fmt.Println("Exit status:")
+	fmt.Println("  0    Success")
+	fmt.Println("  1    Other error, e.g. an unreadable config file")
+	fmt.Println("  2    Bad flags or arguments")
+	fmt.Println("  3    Network error, or a response that can't be read")
+	fmt.Println("  4    HTTP error status, e.g. 404")
+	fmt.Println("  5    No readable content in the page")
+	fmt.Println("  6    Export file couldn't be written")
+	fmt.Println("  130  Interrupted")
+	fmt.Println("With several URLs the first failure decides; --batch fails only if every URL did.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  getwebsite example.com")
+	fmt.Println("  getwebsite https://news.ycombinator.com --pipe")
+	fmt.Println("  getwebsite blaze.design --width 120")
+	fmt.Println("  getwebsite blaze.design --toc")
+	fmt.Println("  getwebsite blaze.design --export article.md")
+	fmt.Println("  getwebsite blaze.design --export article.html")
+	fmt.Println("  getwebsite blaze.design --format json | jq .title")
+	fmt.Println("  getwebsite --batch urls.txt --export-dir out/ --rate-limit 2s")
 }
@@ -0,0 +1,154 @@
+// Package getwebsite fetches web pages, extracts the readable article and
+// renders it for the terminal, for programs that want to embed the reader.
+//
+//	article, err := getwebsite.Read("example.com", getwebsite.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Print(getwebsite.RenderString(article, getwebsite.Options{Width: 80}))
+package getwebsite
+
+import (
+	"context"
+
+	"github.com/0xblz/getwebsite/internal/fetcher"
+	"github.com/0xblz/getwebsite/internal/loader"
+	"github.com/0xblz/getwebsite/internal/parser"
+	"github.com/0xblz/getwebsite/internal/renderer"
+)
+
+// An Article is a parsed page: its metadata, content blocks and links.
+type Article = parser.Article
+
+// A ContentBlock is one heading, paragraph, code block, list, quote, image,
+// rule, table or embedded video of an Article. Its Text and Items carry
+// inline formatting (bold, code and so on) as private-use runes; StripInline
+// removes them.
+type ContentBlock = parser.ContentBlock
+
+// A Link is a numbered link footnote of an Article.
+type Link = parser.Link
+
+// BlockType says what kind of ContentBlock a block is.
+type BlockType = parser.BlockType
+
+// The kinds of ContentBlock.
+const (
+	BlockHeading   = parser.BlockHeading
+	BlockParagraph = parser.BlockParagraph
+	BlockCode      = parser.BlockCode
+	BlockList      = parser.BlockList
+	BlockQuote     = parser.BlockQuote
+	BlockImage     = parser.BlockImage
+	BlockHR        = parser.BlockHR
+	BlockTable     = parser.BlockTable
+	BlockSummary   = parser.BlockSummary
+	BlockEmbed     = parser.BlockEmbed
+)
+
+// The kinds of embed in ContentBlock.Media.
+const (
+	MediaVideo = parser.MediaVideo
+	MediaAudio = parser.MediaAudio
+	MediaEmbed = parser.MediaEmbed // any other embedded player
+)
+
+// StripInline returns the plain words of a ContentBlock's Text or one of its
+// Items, without the inline formatting marks or abbreviation expansions.
+// JSON encoding strips them already.
+func StripInline(s string) string {
+	return parser.StripInline(s)
+}
+
+// FetchOptions configures the HTTP client: timeout, User-Agent, headers,
+// cookies, proxy, rate limit and size limit.
+type FetchOptions = fetcher.Options
+
+// RenderOptions configures terminal rendering: theme, images, code style and
+// so on.
+type RenderOptions = renderer.Options
+
+// Theme is a set of colors for RenderOptions.Theme.
+type Theme = renderer.Theme
+
+// A Renderer lays an article out for the terminal.
+type Renderer = renderer.Renderer
+
+// A BlockRenderer renders one ContentBlock; see Options.BlockRenderers. The
+// block's text still holds its formatting marks, for StripInline to remove.
+type BlockRenderer = renderer.BlockRenderer
+
+// DefaultBlockRenderer returns the built-in renderer for t.
+func DefaultBlockRenderer(t BlockType) BlockRenderer {
+	return renderer.DefaultBlockRenderer(t)
+}
+
+// Options configures Read and RenderString. The zero value works.
+type Options struct {
+	Fetch  FetchOptions
+	Render RenderOptions
+
+	// Width is the width RenderString lays the article out at; 0 means 90.
+	Width int
+
+	// KeepTracking leaves utm_* and other tracking parameters on link URLs.
+	KeepTracking bool
+
+	// Exclude lists CSS selectors of elements to leave out of the article,
+	// such as "div.related".
+	Exclude []string
+
+	// BlockRenderers replace the built-in rendering of the given block types
+	// in RenderString.
+	BlockRenderers map[BlockType]BlockRenderer
+}
+
+// defaultWidth is used when Options.Width is zero.
+const defaultWidth = 90
+
+// Read fetches url and extracts its article. A missing scheme means https.
+func Read(url string, opts Options) (*Article, error) {
+	return ReadContext(context.Background(), url, opts)
+}
+
+// ReadContext is Read, giving up once ctx is done.
+func ReadContext(ctx context.Context, url string, opts Options) (*Article, error) {
+	url = fetcher.NormalizeURL(url)
+	fetch := opts.Fetch
+	if fetch.Sites == nil {
+		// Credentials go to the page asked for and nowhere else
+		fetch.Sites = []string{url}
+	}
+	article, err := loader.LoadContext(ctx, fetcher.New(fetch), url, parser.Options{Exclude: opts.Exclude})
+	if err != nil {
+		return nil, err
+	}
+	if !opts.KeepTracking {
+		parser.StripTrackingParams(article)
+	}
+	return article, nil
+}
+
+// RenderString renders article as styled terminal text, the way getwebsite
+// prints it in pipe mode.
+func RenderString(article *Article, opts Options) string {
+	width := opts.Width
+	if width <= 0 {
+		width = defaultWidth
+	}
+	render := opts.Render
+	if render.Fetcher == nil {
+		// Download images with the same proxy, headers and cookies as Read;
+		// credentials only go to images on the article's own host
+		fetch := opts.Fetch
+		if fetch.Sites == nil {
+			fetch.Sites = []string{article.URL}
+		}
+		render.Fetcher = fetcher.New(fetch)
+	}
+	r := renderer.New(width, render)
+	for t, fn := range opts.BlockRenderers {
+		r.SetBlockRenderer(t, fn)
+	}
+	return r.RenderArticle(article)
+}
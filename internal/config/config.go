@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds defaults read from the config file. Command-line flags
+// override these.
+type Config struct {
+	Width     int
+	Timeout   time.Duration
+	Images    bool
+	UserAgent string
+	Theme     string            // preset theme name; empty means detect from the terminal
+	CodeStyle string            // chroma style for code blocks
+	Colors    map[string]string // overrides for the theme colors
+}
+
+// Default returns the built-in defaults used when there is no config file.
+func Default() Config {
+	return Config{
+		Width:   90,
+		Timeout: 15 * time.Second,
+		Images:  true,
+		Colors:  make(map[string]string),
+	}
+}
+
+// Path returns $XDG_CONFIG_HOME/getwebsite/config.toml, falling back to
+// ~/.config when XDG_CONFIG_HOME is unset.
+func Path() string {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "getwebsite", "config.toml")
+}
+
+// Load reads the config file on top of the defaults. A missing file is not an
+// error.
+//
+// Only a small subset of TOML is understood: comments, a [colors] table and
+// key = value pairs whose values are strings, integers or booleans.
+//
+//	width = 120
+//	timeout = "30s"
+//	images = false
+//	user_agent = "Mozilla/5.0"
+//	theme = "light"
+//	code_style = "github"
+//
+//	[colors]
+//	heading = "#ff8800"
+func Load() (Config, error) {
+	cfg := Default()
+	path := Path()
+	if path == "" {
+		return cfg, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section != "colors" {
+				return cfg, fmt.Errorf("%s:%d: unknown table [%s]", path, n, section)
+			}
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return cfg, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		key = strings.TrimSpace(key)
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return cfg, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if err := cfg.set(section, key, value); err != nil {
+			return cfg, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	return cfg, scanner.Err()
+}
+
+// set applies one key from the given table.
+func (c *Config) set(section, key string, value any) error {
+	if section == "colors" {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("color %s must be a string", key)
+		}
+		c.Colors[key] = s
+		return nil
+	}
+
+	switch key {
+	case "width":
+		n, ok := value.(int)
+		if !ok || n <= 0 {
+			return fmt.Errorf("width must be a positive integer")
+		}
+		c.Width = n
+	case "timeout":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("timeout must be a duration string like \"30s\"")
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", s)
+		}
+		c.Timeout = d
+	case "images":
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("images must be true or false")
+		}
+		c.Images = b
+	case "user_agent":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("user_agent must be a string")
+		}
+		c.UserAgent = s
+	case "theme":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("theme must be a string")
+		}
+		c.Theme = s
+	case "code_style":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("code_style must be a string")
+		}
+		c.CodeStyle = s
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	return nil
+}
+
+// parseValue parses a TOML string, integer or boolean.
+func parseValue(raw string) (any, error) {
+	switch {
+	case raw == "true":
+		return true, nil
+	case raw == "false":
+		return false, nil
+	case strings.HasPrefix(raw, `"`):
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", raw)
+		}
+		return s, nil
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return nil, fmt.Errorf("invalid string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(raw, "_", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %s", raw)
+	}
+	return n, nil
+}
+
+// stripComment removes a trailing # comment that isn't inside a string.
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
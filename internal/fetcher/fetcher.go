@@ -1,25 +1,315 @@
 package fetcher
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/net/proxy"
 )
 
+// maxRetries bounds how many times a 429 response is retried.
+const maxRetries = 3
+
+// maxRetryAfter caps how long a server can ask us to wait before retrying.
+const maxRetryAfter = 2 * time.Minute
+
+// DefaultUserAgent is sent when Options.UserAgent is empty.
+const DefaultUserAgent = "Mozilla/5.0 (compatible; getwebsite/1.0)"
+
+// Options controls optional fetcher behavior.
+type Options struct {
+	Timeout   time.Duration  // per-request timeout; 0 means 15s
+	UserAgent string         // User-Agent header; empty means DefaultUserAgent
+	RateLimit time.Duration  // minimum delay between requests to the same host
+	Headers   http.Header    // extra request headers; these replace the defaults
+	Cookies   []*http.Cookie // cookies to send; see New for how Domain is used
+	Proxy     *url.URL       // proxy for all requests; nil means use the environment
+	Force     bool           // accept responses that don't look like HTML
+	MaxSize   int64          // largest response body to read; 0 means DefaultMaxSize
+	User      string         // HTTP Basic auth user for URLs without user:pass@ of their own
+	Password  string         // HTTP Basic auth password that goes with User
+
+	// Sites are the URLs the user asked for. User, Password and Cookies
+	// without a Domain are only sent to their hosts, never to image hosts,
+	// followed links or redirect targets elsewhere; with no Sites they
+	// aren't sent at all.
+	Sites []string
+
+	// MaxRedirects is how many redirects to follow; 0 means
+	// DefaultMaxRedirects and a negative value means none.
+	MaxRedirects int
+	// OnRedirect, when set, is called before each redirect is followed.
+	OnRedirect func(from, to string)
+}
+
+// DefaultMaxRedirects is how many redirects are followed when
+// Options.MaxRedirects is zero.
+const DefaultMaxRedirects = 10
+
+// RedirectError reports a page that redirected more times than allowed.
+type RedirectError struct {
+	URL string // the redirect that was not followed
+	Max int
+}
+
+func (e *RedirectError) Error() string {
+	if e.Max == 0 {
+		return fmt.Sprintf("redirected to %s, not following (--max-redirects is 0)", e.URL)
+	}
+	return fmt.Sprintf("too many redirects (limit %d), stopped before %s (redirect loop? raise --max-redirects)", e.Max, e.URL)
+}
+
+// DefaultMaxSize caps response bodies so a huge or endless download can't
+// exhaust memory.
+const DefaultMaxSize = 10 << 20
+
 type Fetcher struct {
-	client *http.Client
+	client    *http.Client
+	userAgent string
+	headers   http.Header
+	perHost   time.Duration
+	force     bool
+	maxSize   int64
+	user      string
+	password  string
+	authHosts map[string]bool // hosts of Options.Sites
+	identity  string
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostState serializes requests to one host and remembers when the last one
+// finished.
+type hostState struct {
+	mu   sync.Mutex
+	last time.Time
 }
 
-func New() *Fetcher {
+// New returns a Fetcher. When opts.RateLimit is set, requests to the same host
+// are sent one at a time, at least RateLimit apart.
+//
+// Cookies without a Domain go to the hosts of opts.Sites only. A Domain with
+// a leading dot (".example.com") covers its subdomains; without one the
+// cookie only goes to that exact host.
+func New(opts Options) *Fetcher {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	// The jar also keeps cookies that sites set during the run, so they're
+	// sent again on redirects and later requests
+	jar, _ := cookiejar.New(nil)
+	var sites []*url.URL
+	for _, site := range opts.Sites {
+		if u, err := url.Parse(site); err == nil && u.Host != "" {
+			sites = append(sites, u)
+		}
+	}
+	for _, c := range opts.Cookies {
+		if c.Domain == "" {
+			// Host-only cookies for each site, on all its paths
+			siteCookie := *c
+			if siteCookie.Path == "" {
+				siteCookie.Path = "/"
+			}
+			for _, site := range sites {
+				jar.SetCookies(&url.URL{Scheme: site.Scheme, Host: site.Host, Path: "/"}, []*http.Cookie{&siteCookie})
+			}
+			continue
+		}
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		host := strings.TrimPrefix(c.Domain, ".")
+		if host == c.Domain {
+			// No leading dot: only send it to that exact host
+			hostOnly := *c
+			hostOnly.Domain = ""
+			c = &hostOnly
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: c.Path}, []*http.Cookie{c})
+	}
+
+	authHosts := make(map[string]bool)
+	for _, site := range sites {
+		authHosts[strings.ToLower(site.Host)] = true
+	}
+
 	return &Fetcher{
 		client: &http.Client{
-			Timeout: 15 * time.Second,
+			Timeout:       timeout,
+			Jar:           jar,
+			Transport:     newTransport(opts.Proxy),
+			CheckRedirect: checkRedirect(opts.MaxRedirects, opts.OnRedirect),
 		},
+		userAgent: userAgent,
+		headers:   opts.Headers,
+		perHost:   opts.RateLimit,
+		force:     opts.Force,
+		maxSize:   maxSize,
+		user:      opts.User,
+		password:  opts.Password,
+		authHosts: authHosts,
+		identity:  identity(opts, userAgent),
+		hosts:     make(map[string]*hostState),
+	}
+}
+
+// Identity describes what, besides the URL, can change the page a server
+// sends this fetcher: its User-Agent, extra headers, cookies and Basic auth.
+// Fetchers with the same options have the same identity. It holds the
+// credentials, so hash it before storing it anywhere.
+func (f *Fetcher) Identity() string {
+	return f.identity
+}
+
+// identity builds the string Identity returns.
+func identity(opts Options, userAgent string) string {
+	var b strings.Builder
+	b.WriteString(userAgent + "\x00" + opts.User + "\x00" + opts.Password)
+	keys := make([]string, 0, len(opts.Headers))
+	for key := range opts.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString("\x00" + key + ": " + strings.Join(opts.Headers[key], ", "))
+	}
+	for _, c := range opts.Cookies {
+		b.WriteString("\x00" + c.Name + "=" + c.Value + "; " + c.Domain + c.Path)
+	}
+	return b.String()
+}
+
+// StatusError reports a response with a status other than 200 OK.
+type StatusError struct {
+	Code int
+	URL  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d for %s", e.Code, e.URL)
+}
+
+// checkRedirect returns an http.Client.CheckRedirect that follows up to max
+// redirects (see Options.MaxRedirects), reporting each one to onRedirect.
+func checkRedirect(max int, onRedirect func(from, to string)) func(*http.Request, []*http.Request) error {
+	switch {
+	case max == 0:
+		max = DefaultMaxRedirects
+	case max < 0:
+		max = 0
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return &RedirectError{URL: req.URL.String(), Max: max}
+		}
+		if onRedirect != nil {
+			onRedirect(via[len(via)-1].URL.String(), req.URL.String())
+		}
+		return nil
+	}
+}
+
+// ParseProxy parses a --proxy value, which must be an http://, https:// or
+// socks5:// URL with a host.
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q (want http://, https:// or socks5://host:port)", raw)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return u, nil
+	case "socks5", "socks5h":
+		if _, err := socksDialer(u); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q (want http, https or socks5)", u.Scheme)
+}
+
+// socksDialer returns the dialer that connects through a socks5:// proxy.
+func socksDialer(proxyURL *url.URL) (proxy.ContextDialer, error) {
+	dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", proxyURL.Redacted(), err)
+	}
+	d, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("proxy %s: dialer can't be cancelled", proxyURL.Redacted())
+	}
+	return d, nil
+}
+
+// newTransport returns a transport that sends requests through proxyURL, or
+// when it is nil, through the proxy named by HTTP_PROXY, HTTPS_PROXY or
+// ALL_PROXY (honoring NO_PROXY for the first two). A SOCKS proxy that can't
+// be set up fails every request rather than letting it go out directly.
+func newTransport(proxyURL *url.URL) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	switch {
+	case proxyURL == nil:
+		transport.Proxy = proxyFromEnvironment
+	case proxyURL.Scheme == "socks5" || proxyURL.Scheme == "socks5h":
+		transport.Proxy = nil
+		dialer, err := socksDialer(proxyURL)
+		if err != nil {
+			transport.DialContext = func(context.Context, string, string) (net.Conn, error) {
+				return nil, err
+			}
+			break
+		}
+		transport.DialContext = dialer.DialContext
+	default:
+		transport.Proxy = http.ProxyURL(proxyURL)
 	}
+	return transport
 }
 
+// proxyFromEnvironment extends http.ProxyFromEnvironment with ALL_PROXY, which
+// curl and many other tools use for SOCKS proxies.
+func proxyFromEnvironment(req *http.Request) (*url.URL, error) {
+	if u, err := http.ProxyFromEnvironment(req); u != nil || err != nil {
+		return u, err
+	}
+	all := os.Getenv("ALL_PROXY")
+	if all == "" {
+		all = os.Getenv("all_proxy")
+	}
+	if all == "" {
+		return nil, nil
+	}
+	return ParseProxy(all)
+}
+
+// NormalizeURL trims url and adds https:// when it has no scheme. Userinfo
+// (user:pass@host) is kept; Fetch sends it as Basic auth.
 func NormalizeURL(url string) string {
 	url = strings.TrimSpace(url)
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
@@ -28,28 +318,295 @@ func NormalizeURL(url string) string {
 	return url
 }
 
-func (f *Fetcher) Fetch(url string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// Result is a fetched page or image.
+type Result struct {
+	Body []byte
+	// URL is where the page was found after following HTTP redirects. Relative
+	// links in Body resolve against it, not against the URL that was asked for.
+	URL string
+	// ContentType is the response's Content-Type header.
+	ContentType string
+}
+
+func (f *Fetcher) Fetch(url string) (*Result, error) {
+	return f.FetchContext(context.Background(), url)
+}
+
+// FetchContext is Fetch, giving up as soon as ctx is done, including while
+// waiting out the rate limit or a Retry-After.
+func (f *Fetcher) FetchContext(ctx context.Context, url string) (*Result, error) {
+	return f.fetchWithRetries(ctx, url, f.fetchOnce)
+}
+
+// FetchImageContext downloads the image at url the way FetchContext downloads
+// a page: through the same proxy, with the same headers, cookies and auth, and
+// within the rate limit. A body over maxSize is an error, not cut short.
+func (f *Fetcher) FetchImageContext(ctx context.Context, url string, maxSize int64) (*Result, error) {
+	return f.fetchWithRetries(ctx, url, func(ctx context.Context, url string) (*Result, time.Duration, error) {
+		return f.fetchImageOnce(ctx, url, maxSize)
+	})
+}
+
+// fetchWithRetries runs fetch for url, retrying while it reports a 429 Too
+// Many Requests. Only with a rate limit are requests to the same host sent
+// one at a time; otherwise they run concurrently.
+func (f *Fetcher) fetchWithRetries(ctx context.Context, url string, fetch func(context.Context, string) (*Result, time.Duration, error)) (*Result, error) {
+	for attempt := 0; ; attempt++ {
+		result, retryAfter, err := f.fetchLimited(ctx, url, fetch)
+		if retryAfter < 0 || attempt >= maxRetries {
+			return result, err
+		}
+		if err := sleepContext(ctx, retryAfter); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fetchLimited runs fetch for url, first waiting until the rate limit allows
+// another request to its host. Without a rate limit it just runs fetch.
+func (f *Fetcher) fetchLimited(ctx context.Context, url string, fetch func(context.Context, string) (*Result, time.Duration, error)) (*Result, time.Duration, error) {
+	if f.perHost <= 0 {
+		return fetch(ctx, url)
+	}
+	host := f.host(url)
+	host.mu.Lock()
+	defer host.mu.Unlock()
+	if err := sleepContext(ctx, time.Until(host.last.Add(f.perHost))); err != nil {
+		return nil, -1, err
+	}
+	defer func() { host.last = time.Now() }()
+	return fetch(ctx, url)
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// host returns the rate limiting state for the host of rawURL.
+func (f *Fetcher) host(rawURL string) *hostState {
+	name := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		name = u.Host
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h, ok := f.hosts[name]
+	if !ok {
+		h = &hostState{}
+		f.hosts[name] = h
+	}
+	return h
+}
+
+// fetchOnce performs a single request. On 429 Too Many Requests it also returns
+// how long to wait before retrying; otherwise retryAfter is negative.
+func (f *Fetcher) fetchOnce(ctx context.Context, url string) (result *Result, retryAfter time.Duration, err error) {
+	retryAfter = -1
+	req, err := f.newRequest(ctx, url, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, retryAfter, err
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; getwebsite/1.0)")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	url = req.URL.String()
 
 	resp, err := f.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetching %s: %w", url, err)
+		var redirectErr *RedirectError
+		if errors.As(err, &redirectErr) {
+			return nil, retryAfter, fmt.Errorf("fetching %s: %w", url, redirectErr)
+		}
+		return nil, retryAfter, fmt.Errorf("fetching %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d for %s", resp.StatusCode, url)
+		return nil, retryAfter, &StatusError{Code: resp.StatusCode, URL: url}
+	}
+	if ct := resp.Header.Get("Content-Type"); !f.force && !isHTMLContentType(ct) {
+		mediaType, _, _ := mime.ParseMediaType(ct)
+		if mediaType == "" {
+			mediaType = ct
+		}
+		return nil, retryAfter, fmt.Errorf("unsupported content type %s for %s (use --force to parse anyway)", mediaType, url)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	if resp.ContentLength > f.maxSize {
+		return nil, retryAfter, fmt.Errorf("response from %s is %s, over the %s limit (raise it with --max-size)",
+			url, FormatSize(resp.ContentLength), FormatSize(f.maxSize))
+	}
+	// Read one byte past the limit to tell a body that fits exactly from one
+	// that was cut off
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
 	if err != nil {
-		return nil, fmt.Errorf("reading body: %w", err)
+		return nil, retryAfter, fmt.Errorf("reading body: %w", err)
+	}
+	if int64(len(body)) > f.maxSize {
+		return nil, retryAfter, fmt.Errorf("response from %s is over the %s limit (raise it with --max-size)",
+			url, FormatSize(f.maxSize))
+	}
+
+	return &Result{Body: body, URL: resp.Request.URL.String(), ContentType: resp.Header.Get("Content-Type")}, retryAfter, nil
+}
+
+// fetchImageOnce is fetchOnce for an image: any content type is accepted and
+// the body can be at most maxSize.
+func (f *Fetcher) fetchImageOnce(ctx context.Context, url string, maxSize int64) (result *Result, retryAfter time.Duration, err error) {
+	retryAfter = -1
+	req, err := f.newRequest(ctx, url, "image/*,*/*;q=0.8")
+	if err != nil {
+		return nil, retryAfter, err
+	}
+	url = req.URL.String()
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, retryAfter, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, retryAfter, &StatusError{Code: resp.StatusCode, URL: url}
 	}
 
-	return body, nil
+	// Read one byte past the limit so an image that doesn't fit is an error,
+	// not saved or cached cut off
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, retryAfter, fmt.Errorf("reading image: %w", err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, retryAfter, fmt.Errorf("image %s is over the %s limit", url, FormatSize(maxSize))
+	}
+
+	return &Result{Body: body, URL: resp.Request.URL.String(), ContentType: resp.Header.Get("Content-Type")}, retryAfter, nil
+}
+
+// newRequest returns a GET request for url that asks for accept and carries
+// the fetcher's auth, User-Agent and extra headers. Cookies come from the jar.
+func (f *Fetcher) newRequest(ctx context.Context, url, accept string) (*http.Request, error) {
+	url, user, password, hasAuth := splitUserinfo(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	// Like curl without --location-trusted, --user only goes to the site
+	// asked for. Go's client drops the header itself on a redirect elsewhere.
+	if !hasAuth && f.user != "" && f.authHosts[strings.ToLower(req.URL.Host)] {
+		user, password, hasAuth = f.user, f.password, true
+	}
+	if hasAuth {
+		req.SetBasicAuth(user, password)
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", accept)
+	for key, values := range f.headers {
+		req.Header[key] = values
+	}
+	return req, nil
+}
+
+// splitUserinfo removes user:pass@ from rawURL, so the credentials go in the
+// Authorization header and never show up in error messages.
+func splitUserinfo(rawURL string) (clean, user, password string, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL, "", "", false
+	}
+	user = u.User.Username()
+	password, _ = u.User.Password()
+	u.User = nil
+	return u.String(), user, password, true
+}
+
+// sizeUnits are the suffixes ParseSize accepts, longest first so "MB" is
+// matched before "B".
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a human size like "20MB", "512K" or "1048576". Units are
+// powers of 1024 and case-insensitive.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 20MB)", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatSize formats a byte count with the largest unit that fits.
+func FormatSize(n int64) string {
+	for _, unit := range sizeUnits[:3] {
+		if n >= unit.bytes {
+			return strconv.FormatFloat(float64(n)/float64(unit.bytes), 'f', -1, 64) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+// isHTMLContentType reports whether a Content-Type header is worth handing to
+// the parser: HTML, XHTML, other XML and text types, or a missing or
+// unparseable type.
+func isHTMLContentType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" {
+		return true
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/xhtml+xml",
+		mediaType == "application/xml",
+		strings.HasSuffix(mediaType, "+xml") && !strings.HasPrefix(mediaType, "image/"):
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date. Missing or malformed values fall back to one second.
+func parseRetryAfter(value string) time.Duration {
+	wait := time.Second
+	if secs, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && secs >= 0 {
+		wait = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		wait = time.Until(t)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait
 }
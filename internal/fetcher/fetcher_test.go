@@ -0,0 +1,199 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBasicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "alice" || password != "s3cret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body><p>ok</p></body></html>"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		opts Options
+	}{
+		{"userinfo", strings.Replace(srv.URL, "http://", "http://alice:s3cret@", 1), Options{}},
+		{"options", srv.URL, Options{User: "alice", Password: "s3cret", Sites: []string{srv.URL}}},
+		{"userinfo wins", strings.Replace(srv.URL, "http://", "http://alice:s3cret@", 1), Options{User: "bob", Password: "wrong"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New(tt.opts).Fetch(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(result.Body), "ok") {
+				t.Errorf("body = %q", result.Body)
+			}
+			if strings.Contains(result.URL, "s3cret") {
+				t.Errorf("result URL %q keeps the password", result.URL)
+			}
+		})
+	}
+
+	if _, err := New(Options{}).Fetch(srv.URL); err == nil {
+		t.Error("fetch without credentials succeeded")
+	}
+	if _, err := New(Options{User: "alice", Password: "s3cret"}).Fetch(srv.URL); err == nil {
+		t.Error("--user credentials were sent to a host not in Sites")
+	}
+}
+
+func TestBasicAuthStaysOnSite(t *testing.T) {
+	var auth []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth = append(auth, r.Host+" "+r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}
+	site := httptest.NewServer(http.HandlerFunc(handler))
+	defer site.Close()
+	images := httptest.NewServer(http.HandlerFunc(handler))
+	defer images.Close()
+
+	f := New(Options{User: "alice", Password: "s3cret", Sites: []string{site.URL + "/article"}})
+	for _, u := range []string{site.URL + "/a.png", images.URL + "/b.png"} {
+		if _, err := f.FetchImageContext(context.Background(), u, 1<<20); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(auth) != 2 {
+		t.Fatalf("got %d requests, want 2", len(auth))
+	}
+	if host, header, _ := strings.Cut(auth[0], " "); header == "" {
+		t.Errorf("image on the site's own host %s got no Authorization header", host)
+	}
+	if host, header, _ := strings.Cut(auth[1], " "); header != "" {
+		t.Errorf("image on another host %s got Authorization %q", host, header)
+	}
+}
+
+func TestNoRateLimitFetchesConcurrently(t *testing.T) {
+	// Each request waits until both have arrived, so serialized fetches
+	// would time out
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived.Done()
+		done := make(chan struct{})
+		go func() { arrived.Wait(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			http.Error(w, "requests were serialized", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	f := New(Options{})
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := f.Fetch(srv.URL)
+			errs <- err
+		}()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRateLimitSpacesRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	const limit = 100 * time.Millisecond
+	f := New(Options{RateLimit: limit})
+	start := time.Now()
+	for range 3 {
+		if _, err := f.Fetch(srv.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*limit {
+		t.Errorf("three fetches took %v, want at least %v", elapsed, 2*limit)
+	}
+}
+
+func TestDomainlessCookieStaysOnSite(t *testing.T) {
+	var got []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Get("Cookie"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}
+	site := httptest.NewServer(http.HandlerFunc(handler))
+	defer site.Close()
+	other := httptest.NewServer(http.HandlerFunc(handler))
+	defer other.Close()
+
+	f := New(Options{
+		Cookies: []*http.Cookie{{Name: "session", Value: "abc"}},
+		Sites:   []string{site.URL},
+	})
+	// Cookies don't tell ports apart, so reach the other server by name
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	for _, u := range []string{site.URL + "/deep/page", otherURL + "/"} {
+		if _, err := f.Fetch(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d requests, want 2", len(got))
+	}
+	if got[0] != "session=abc" {
+		t.Errorf("site got Cookie %q, want %q", got[0], "session=abc")
+	}
+	if got[1] != "" {
+		t.Errorf("another host got Cookie %q, want none", got[1])
+	}
+}
+
+func TestSocksProxyNeverGoesDirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	// Nothing listens on the proxy's port, so the fetch has to fail
+	proxyURL, err := ParseProxy("socks5://127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Options{Proxy: proxyURL}).Fetch(srv.URL); err == nil {
+		t.Error("fetch through an unreachable SOCKS proxy succeeded")
+	}
+}
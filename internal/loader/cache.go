@@ -0,0 +1,122 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/0xblz/getwebsite/internal/fetcher"
+	"github.com/0xblz/getwebsite/internal/parser"
+)
+
+// DefaultCacheTTL is how long a parsed article is reused unless told otherwise.
+const DefaultCacheTTL = 10 * time.Minute
+
+// CacheOptions controls the on-disk article cache of LoadCachedContext.
+type CacheOptions struct {
+	TTL     time.Duration // how long a saved article is reused; 0 turns the cache off
+	Refresh bool          // load afresh even if a saved copy is current, and save that
+}
+
+// LoadCachedContext is LoadContext through the on-disk article cache: an
+// article saved within cache.TTL is returned without fetching anything, and a
+// fresh load is saved for next time.
+func LoadCachedContext(ctx context.Context, f *fetcher.Fetcher, url string, opts parser.Options, cache CacheOptions) (*parser.Article, error) {
+	if cache.TTL <= 0 {
+		return LoadContext(ctx, f, url, opts)
+	}
+	key := articleCacheKey(url, f.Identity(), opts)
+	if !cache.Refresh {
+		if article, ok := readCachedArticle(key, cache.TTL); ok {
+			return article, nil
+		}
+	}
+	article, err := LoadContext(ctx, f, url, opts)
+	if err != nil {
+		return nil, err
+	}
+	writeCachedArticle(key, article)
+	return article, nil
+}
+
+// articleCacheDir returns $XDG_CACHE_HOME/getwebsite/articles, falling back
+// to ~/.cache when XDG_CACHE_HOME is unset.
+func articleCacheDir() string {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		base = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(base, "getwebsite", "articles")
+}
+
+// articleCacheKey hashes a URL, the fetcher identity the page was requested
+// with and the parser options that change what is extracted from it into a
+// cache file name. A page fetched with cookies or credentials is then never
+// served to a run without them, or the other way around.
+func articleCacheKey(url, identity string, opts parser.Options) string {
+	sum := sha256.Sum256([]byte(url + "\x00" + identity + "\x00" + strings.Join(opts.Exclude, "\x00")))
+	return hex.EncodeToString(sum[:]) + ".gob"
+}
+
+// readCachedArticle returns the article saved under key if it is younger
+// than ttl. Articles are stored as gob rather than JSON, whose encoding
+// drops the inline formatting marks.
+func readCachedArticle(key string, ttl time.Duration) (*parser.Article, bool) {
+	dir := articleCacheDir()
+	if dir == "" {
+		return nil, false
+	}
+	path := filepath.Join(dir, key)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > ttl {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var article parser.Article
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&article); err != nil {
+		return nil, false
+	}
+	return &article, true
+}
+
+// writeCachedArticle saves article under key, through a temporary file so a
+// concurrent run never reads half of it. Pages may have been fetched with
+// credentials, so the directory is private and the files, made by
+// os.CreateTemp, are 0600.
+func writeCachedArticle(key string, article *parser.Article) {
+	dir := articleCacheDir()
+	if dir == "" {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(article); err != nil {
+		return
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(dir, key+".*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(tmp.Name(), filepath.Join(dir, key)) != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,82 @@
+package loader
+
+import (
+	"context"
+
+	"github.com/0xblz/getwebsite/internal/fetcher"
+	"github.com/0xblz/getwebsite/internal/parser"
+)
+
+// minArticleText is how much text an article needs before we stop looking
+// for a meta-refresh or canonical page that might have the real content.
+const minArticleText = 200
+
+// maxHops bounds how many meta-refresh or canonical links are followed.
+const maxHops = 1
+
+// Load fetches and parses url. If the page yields little or no article text
+// but points elsewhere with a meta refresh or canonical link, that page is
+// fetched and parsed instead.
+func Load(f *fetcher.Fetcher, url string, opts parser.Options) (*parser.Article, error) {
+	return LoadContext(context.Background(), f, url, opts)
+}
+
+// LoadContext is Load, with every fetch made under ctx.
+//
+// Links are resolved against the URL the page ends up at after HTTP
+// redirects, but the article keeps the URL that was asked for.
+func LoadContext(ctx context.Context, f *fetcher.Fetcher, url string, opts parser.Options) (*parser.Article, error) {
+	requested := url
+	result, err := f.FetchContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	html, url := result.Body, result.URL
+	article, err := parser.ParseWithOptions(html, url, opts)
+	if err == nil {
+		article.URL = requested
+	}
+
+	visited := map[string]bool{requested: true, url: true}
+	for hop := 0; hop < maxHops; hop++ {
+		if err == nil && textLength(article) >= minArticleText {
+			break
+		}
+		target := parser.RedirectTarget(html, url)
+		if target == "" || visited[target] {
+			break
+		}
+		visited[target] = true
+
+		targetResult, fetchErr := f.FetchContext(ctx, target)
+		if fetchErr != nil {
+			break
+		}
+		targetHTML := targetResult.Body
+		targetArticle, parseErr := parser.ParseWithOptions(targetHTML, targetResult.URL, opts)
+		if parseErr != nil || (err == nil && textLength(targetArticle) <= textLength(article)) {
+			break
+		}
+		targetArticle.URL = target
+		html, url, article, err = targetHTML, targetResult.URL, targetArticle, nil
+	}
+
+	return article, err
+}
+
+// textLength counts the characters of body text in an article.
+func textLength(article *parser.Article) int {
+	n := 0
+	for _, block := range article.Content {
+		n += len(block.Text)
+		for _, item := range block.Items {
+			n += len(item)
+		}
+		for _, row := range block.Rows {
+			for _, cell := range row {
+				n += len(cell)
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Inline formatting is embedded in block text (paragraphs, list items, quotes)
+// as pairs of marker runes from the Unicode private use area (U+E000–U+E0FF is
+// reserved for them). This keeps Text a
+// plain string; renderers translate the marks into their own syntax, and
+// StripInline removes them for consumers that only want the words.
+const (
+	MarkBoldOpen       = '\uE000'
+	MarkBoldClose      = '\uE001'
+	MarkItalicOpen     = '\uE002'
+	MarkItalicClose    = '\uE003'
+	MarkCodeOpen       = '\uE004'
+	MarkCodeClose      = '\uE005'
+	MarkKbdOpen        = '\uE006'
+	MarkKbdClose       = '\uE007'
+	MarkHighlightOpen  = '\uE008'
+	MarkHighlightClose = '\uE009'
+	MarkAbbrOpen       = '\uE00A'
+	MarkAbbrTitle      = '\uE00B' // separates an abbreviation from its expansion
+	MarkAbbrClose      = '\uE00C'
+	MarkStrikeOpen     = '\uE00D'
+	MarkStrikeClose    = '\uE00E'
+)
+
+// inlineMarks maps inline HTML elements to the marks that wrap their content.
+var inlineMarks = map[string][2]rune{
+	"strong": {MarkBoldOpen, MarkBoldClose},
+	"b":      {MarkBoldOpen, MarkBoldClose},
+	"em":     {MarkItalicOpen, MarkItalicClose},
+	"i":      {MarkItalicOpen, MarkItalicClose},
+	"code":   {MarkCodeOpen, MarkCodeClose},
+	"kbd":    {MarkKbdOpen, MarkKbdClose},
+	"mark":   {MarkHighlightOpen, MarkHighlightClose},
+	"del":    {MarkStrikeOpen, MarkStrikeClose},
+	"s":      {MarkStrikeOpen, MarkStrikeClose},
+	"strike": {MarkStrikeOpen, MarkStrikeClose},
+}
+
+// IsMark reports whether r is one of the inline formatting marks.
+func IsMark(r rune) bool {
+	return r >= '\uE000' && r <= '\uE0FF'
+}
+
+// StripInline removes all inline formatting marks from s, along with any
+// abbreviation expansions.
+func StripInline(s string) string {
+	var b strings.Builder
+	inTitle := false
+	for _, r := range s {
+		switch {
+		case r == MarkAbbrTitle:
+			inTitle = true
+		case r == MarkAbbrClose:
+			inTitle = false
+		case !inTitle && !IsMark(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// writeAbbr writes an <abbr> element's text wrapped in abbreviation marks. The
+// first time each abbreviation appears, its expansion follows MarkAbbrTitle
+// inside the marks.
+func (ctx *parseContext) writeAbbr(b *strings.Builder, raw, inner, title string) {
+	if inner == "" {
+		return
+	}
+	if r, _ := utf8.DecodeRuneInString(raw); unicode.IsSpace(r) {
+		b.WriteByte(' ')
+	}
+	b.WriteRune(MarkAbbrOpen)
+	b.WriteString(inner)
+	key := StripInline(inner)
+	if title != "" && title != key && !ctx.abbrs[key] {
+		ctx.abbrs[key] = true
+		b.WriteRune(MarkAbbrTitle)
+		b.WriteString(title)
+	}
+	b.WriteRune(MarkAbbrClose)
+	if r, _ := utf8.DecodeLastRuneInString(raw); unicode.IsSpace(r) {
+		b.WriteByte(' ')
+	}
+}
+
+// writeMarked writes inner wrapped in the given marks, keeping any whitespace
+// that surrounded the element in raw outside the marks.
+func writeMarked(b *strings.Builder, raw, inner string, marks [2]rune) {
+	if inner == "" {
+		return
+	}
+	if r, _ := utf8.DecodeRuneInString(raw); unicode.IsSpace(r) {
+		b.WriteByte(' ')
+	}
+	b.WriteRune(marks[0])
+	b.WriteString(inner)
+	b.WriteRune(marks[1])
+	if r, _ := utf8.DecodeLastRuneInString(raw); unicode.IsSpace(r) {
+		b.WriteByte(' ')
+	}
+}
+
+// superscripts and subscripts map characters to their Unicode superscript and
+// subscript forms, for the characters that have one.
+var (
+	superscripts = scriptTable(
+		"0123456789+-−=()abcdefghijklmnoprstuvwxyz",
+		"⁰¹²³⁴⁵⁶⁷⁸⁹⁺⁻⁻⁼⁽⁾ᵃᵇᶜᵈᵉᶠᵍʰⁱʲᵏˡᵐⁿᵒᵖʳˢᵗᵘᵛʷˣʸᶻ",
+	)
+	subscripts = scriptTable(
+		"0123456789+-−=()aehijklmnoprstuvx",
+		"₀₁₂₃₄₅₆₇₈₉₊₋₋₌₍₎ₐₑₕᵢⱼₖₗₘₙₒₚᵣₛₜᵤᵥₓ",
+	)
+)
+
+func scriptTable(from, to string) map[rune]rune {
+	table := make(map[rune]rune)
+	toRunes := []rune(to)
+	for i, r := range []rune(from) {
+		table[r] = toRunes[i]
+	}
+	return table
+}
+
+// writeScript writes the text of a <sup> or <sub> element as Unicode
+// superscript or subscript characters, or when some character has no such
+// form, as prefix + "(" + inner + ")" (e.g. "^(n+k)").
+func writeScript(b *strings.Builder, raw, inner string, table map[rune]rune, prefix string) {
+	if inner == "" {
+		return
+	}
+	if r, _ := utf8.DecodeRuneInString(raw); unicode.IsSpace(r) {
+		b.WriteByte(' ')
+	}
+	if mapped, ok := mapScript(inner, table); ok {
+		b.WriteString(mapped)
+	} else {
+		b.WriteString(prefix + "(" + inner + ")")
+	}
+	if r, _ := utf8.DecodeLastRuneInString(raw); unicode.IsSpace(r) {
+		b.WriteByte(' ')
+	}
+}
+
+// mapScript maps every character of s through table, reporting false if any
+// character (other than formatting marks) has no entry.
+func mapScript(s string, table map[rune]rune) (string, bool) {
+	var b strings.Builder
+	for _, r := range s {
+		if IsMark(r) {
+			b.WriteRune(r)
+			continue
+		}
+		mapped, ok := table[r]
+		if !ok {
+			return "", false
+		}
+		b.WriteRune(mapped)
+	}
+	return b.String(), true
+}
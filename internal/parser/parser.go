@@ -2,29 +2,39 @@ package parser
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
 	readability "github.com/go-shiori/go-readability"
 )
 
 type Article struct {
-	Title       string
-	Description string
-	SiteName    string
-	PublishDate time.Time
-	Content     []ContentBlock
-	Links       []Link
-	RawHTML     string
+	Title        string         `json:"title"`
+	URL          string         `json:"url,omitempty"`
+	Author       string         `json:"author,omitempty"`
+	Description  string         `json:"description,omitempty"`
+	SiteName     string         `json:"site_name,omitempty"`
+	FaviconURL   string         `json:"favicon_url,omitempty"`
+	LeadImageURL string         `json:"lead_image_url,omitempty"` // og:image
+	LeadImageAlt string         `json:"lead_image_alt,omitempty"` // og:image:alt
+	PublishDate  time.Time      `json:"publish_date,omitzero"`
+	Content      []ContentBlock `json:"content"`
+	Links        []Link         `json:"links"`
+	RawHTML      string         `json:"-"` // the cleaned HTML the content was parsed from
 }
 
 type Link struct {
-	Index int
-	Text  string
-	URL   string
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"` // the anchor's title attribute
 }
 
 type BlockType int
@@ -38,26 +48,163 @@ const (
 	BlockImage
 	BlockHR
 	BlockTable
+	BlockSummary // <summary> label of a <details> section
+	BlockEmbed   // <video>, <audio> or an embedded player <iframe>
 )
 
+var blockTypeNames = map[BlockType]string{
+	BlockHeading:   "heading",
+	BlockParagraph: "paragraph",
+	BlockCode:      "code",
+	BlockList:      "list",
+	BlockQuote:     "quote",
+	BlockImage:     "image",
+	BlockHR:        "hr",
+	BlockTable:     "table",
+	BlockSummary:   "summary",
+	BlockEmbed:     "embed",
+}
+
+func (t BlockType) String() string {
+	if name, ok := blockTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("BlockType(%d)", int(t))
+}
+
+// MarshalJSON encodes a BlockType as its lowercase name, e.g. "heading".
+func (t BlockType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
 type ContentBlock struct {
-	Type     BlockType
-	Text     string
-	Level    int        // heading level (1-6)
-	Language string     // code language
-	Items    []string   // list items
-	Ordered  bool       // ordered list
-	Alt      string     // image alt text
-	URL      string     // image URL
-	Rows     [][]string // table rows
-	Header   bool       // table has header row
+	Type      BlockType  `json:"type"`
+	Text      string     `json:"text,omitempty"`
+	Level     int        `json:"level,omitempty"`     // heading level (1-6), or quote depth (1 for a top-level quote)
+	Language  string     `json:"language,omitempty"`  // code language
+	Items     []string   `json:"items,omitempty"`     // list items
+	Ordered   bool       `json:"ordered,omitempty"`   // ordered list
+	Start     int        `json:"start,omitempty"`     // number of an ordered list's first item; 0 means 1
+	Numbering string     `json:"numbering,omitempty"` // ordered list markers: a Numbering* style, "" for 1, 2, 3
+	Alt       string     `json:"alt,omitempty"`       // image alt text
+	URL       string     `json:"url,omitempty"`       // image URL
+	Rows      [][]string `json:"rows,omitempty"`      // table rows
+	Header    bool       `json:"header,omitempty"`    // table has header row
+	Align     []string   `json:"align,omitempty"`     // table column alignment: "left", "center" or "right"
+	Tasks     []string   `json:"tasks,omitempty"`     // per list item: TaskChecked, TaskUnchecked or "" (not a task)
+	Media     string     `json:"media,omitempty"`     // embed kind: MediaVideo, MediaAudio or MediaEmbed
 }
 
+// Task-list states stored in ContentBlock.Tasks.
+const (
+	TaskChecked   = "checked"
+	TaskUnchecked = "unchecked"
+)
+
+// Ordered-list marker styles stored in ContentBlock.Numbering, named after
+// their CSS list-style-type.
+const (
+	NumberingLowerAlpha = "lower-alpha" // a, b, c
+	NumberingUpperAlpha = "upper-alpha" // A, B, C
+	NumberingLowerRoman = "lower-roman" // i, ii, iii
+	NumberingUpperRoman = "upper-roman" // I, II, III
+)
+
+// Embed kinds stored in ContentBlock.Media.
+const (
+	MediaVideo = "video"
+	MediaAudio = "audio"
+	MediaEmbed = "embed" // any other <iframe>
+)
+
+// listNumbering reads the start and type attributes of an <ol>. A missing or
+// invalid start gives 0, meaning the list counts from 1.
+func listNumbering(ol *goquery.Selection) (int, string) {
+	start := 0
+	if v, ok := ol.Attr("start"); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n != 1 {
+			start = n
+		}
+	}
+
+	// type is case-sensitive: "a" and "A" are different styles
+	var numbering string
+	switch v, _ := ol.Attr("type"); strings.TrimSpace(v) {
+	case "a":
+		numbering = NumberingLowerAlpha
+	case "A":
+		numbering = NumberingUpperAlpha
+	case "i":
+		numbering = NumberingLowerRoman
+	case "I":
+		numbering = NumberingUpperRoman
+	}
+	return start, numbering
+}
+
+// MarshalJSON strips inline formatting marks so JSON consumers get plain text.
+func (b ContentBlock) MarshalJSON() ([]byte, error) {
+	type plain ContentBlock
+	p := plain(b)
+	p.Text = StripInline(b.Text)
+	if b.Items != nil {
+		p.Items = make([]string, len(b.Items))
+		for i, item := range b.Items {
+			p.Items[i] = StripInline(item)
+		}
+	}
+	return json.Marshal(p)
+}
+
+// ErrNoContent is returned by Parse when a page has no readable content, even
+// without readability's cleanup.
+var ErrNoContent = errors.New("no readable content found")
+
+// ErrExtract wraps the error when readability can't process a page at all.
+var ErrExtract = errors.New("extracting article")
+
+// minExtractedText is how much text readability has to find before its result
+// is trusted; below this the whole page body is parsed as well, and whichever
+// has more text is used.
+const minExtractedText = 100
+
+// Options controls optional parser behavior.
+type Options struct {
+	// Exclude lists CSS selectors of elements to drop, such as "div.related".
+	// They are removed from the page before readability runs, since it strips
+	// class attributes, and again from the cleaned HTML before extraction.
+	Exclude []string
+
+	// OnPreview, if set, is called with the page's Preview before the body
+	// is extracted, which can take a while on a very large page.
+	OnPreview func(preview *Article)
+}
+
+// CheckSelector reports whether sel is a CSS selector Options.Exclude can use.
+func CheckSelector(sel string) error {
+	if _, err := cascadia.Compile(sel); err != nil {
+		return fmt.Errorf("invalid selector %q: %v", sel, err)
+	}
+	return nil
+}
+
+// Parse extracts the article from a page's HTML, resolving links against
+// pageURL.
 func Parse(rawHTML []byte, pageURL string) (*Article, error) {
-	reader := bytes.NewReader(rawHTML)
+	return ParseWithOptions(rawHTML, pageURL, Options{})
+}
+
+// ParseWithOptions is Parse with optional behavior set by opts.
+func ParseWithOptions(rawHTML []byte, pageURL string, opts Options) (*Article, error) {
+	if opts.OnPreview != nil {
+		opts.OnPreview(Preview(rawHTML, pageURL))
+	}
+	rawHTML = removeElements(rawHTML, opts.Exclude)
+	annotated := annotateForReadability(rawHTML)
+	reader := bytes.NewReader(annotated)
 	doc, err := readability.FromReader(reader, nil)
 	if err != nil {
-		return nil, fmt.Errorf("extracting article: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrExtract, err)
 	}
 
 	// Extract description from readability excerpt, fall back to raw HTML meta tags
@@ -68,22 +215,131 @@ func Parse(rawHTML []byte, pageURL string) (*Article, error) {
 
 	article := &Article{
 		Title:       doc.Title,
+		URL:         pageURL,
+		Author:      doc.Byline,
 		Description: description,
 		SiteName:    doc.SiteName,
 		RawHTML:     doc.Content,
 	}
+	if doc.PublishedTime != nil {
+		article.PublishDate = *doc.PublishedTime
+	}
 
 	if article.Title == "" {
 		article.Title = pageURL
 	}
 
 	base, _ := url.Parse(pageURL)
-	article.Content, article.Links = parseHTML(doc.Content, base)
+	article.FaviconURL = extractFavicon(rawHTML, base)
+	article.LeadImageURL, article.LeadImageAlt = extractLeadImage(rawHTML, doc.Image, base)
+	article.Content, article.Links = parseHTML(doc.Content, base, opts.Exclude)
+
+	// Readability sometimes throws away everything; fall back to the page
+	// body minus scripts and navigation
+	if n := contentLength(article.Content); n < minExtractedText {
+		if body := looseHTML(annotated); body != "" {
+			content, links := parseHTML(body, base, opts.Exclude)
+			if contentLength(content) > n {
+				article.Content, article.Links = content, links
+				article.RawHTML = body
+			}
+		}
+	}
+	if len(article.Content) == 0 {
+		return nil, ErrNoContent
+	}
 
 	return article, nil
 }
 
-func parseHTML(html string, base *url.URL) ([]ContentBlock, []Link) {
+// maxPreviewHead is how far into a page Preview looks for the end of <head>.
+const maxPreviewHead = 1 << 20
+
+// Preview returns the title, site name and description a page's <head>
+// declares, without extracting the article. It has no content; the title
+// falls back to pageURL.
+func Preview(rawHTML []byte, pageURL string) *Article {
+	head := rawHTML[:min(len(rawHTML), maxPreviewHead)]
+	if i := bytes.Index(bytes.ToLower(head), []byte("</head")); i >= 0 {
+		head = head[:i]
+	}
+
+	article := &Article{URL: pageURL, Description: extractMetaDescription(head)}
+	if doc, err := goquery.NewDocumentFromReader(bytes.NewReader(head)); err == nil {
+		article.Title, _ = doc.Find(`meta[property="og:title"]`).Attr("content")
+		if article.Title == "" {
+			article.Title = doc.Find("title").First().Text()
+		}
+		article.Title = cleanText(article.Title)
+		site, _ := doc.Find(`meta[property="og:site_name"]`).Attr("content")
+		article.SiteName = cleanText(site)
+	}
+	if article.Title == "" {
+		article.Title = pageURL
+	}
+	return article
+}
+
+// removeElements returns rawHTML without the elements matching selectors.
+// It returns rawHTML unchanged when there are none or the page can't be
+// parsed.
+func removeElements(rawHTML []byte, selectors []string) []byte {
+	if len(selectors) == 0 {
+		return rawHTML
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(rawHTML))
+	if err != nil {
+		return rawHTML
+	}
+	removed := 0
+	for _, sel := range selectors {
+		matches := doc.Find(sel)
+		removed += matches.Length()
+		matches.Remove()
+	}
+	if removed == 0 {
+		return rawHTML
+	}
+	out, err := doc.Html()
+	if err != nil {
+		return rawHTML
+	}
+	return []byte(out)
+}
+
+// looseHTML returns the page with scripts, styles, forms and page chrome
+// (nav, header, footer, aside) removed, for when readability finds nothing.
+func looseHTML(rawHTML []byte) string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(rawHTML))
+	if err != nil {
+		return ""
+	}
+	doc.Find("script, style, noscript, template, iframe, svg, form, nav, header, footer, aside").Remove()
+	out, err := doc.Html()
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// contentLength counts the characters of text in blocks.
+func contentLength(blocks []ContentBlock) int {
+	n := 0
+	for _, block := range blocks {
+		n += len(block.Text)
+		for _, item := range block.Items {
+			n += len(item)
+		}
+		for _, row := range block.Rows {
+			for _, cell := range row {
+				n += len(cell)
+			}
+		}
+	}
+	return n
+}
+
+func parseHTML(html string, base *url.URL, exclude []string) ([]ContentBlock, []Link) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
 		text := stripTags(html)
@@ -92,8 +348,11 @@ func parseHTML(html string, base *url.URL) ([]ContentBlock, []Link) {
 		}
 		return nil, nil
 	}
+	for _, sel := range exclude {
+		doc.Find(sel).Remove()
+	}
 
-	ctx := &parseContext{base: base}
+	ctx := &parseContext{base: base, linkIndex: make(map[string]int), abbrs: make(map[string]bool)}
 	doc.Find("body").Children().Each(func(_ int, s *goquery.Selection) {
 		ctx.extractBlocks(s)
 	})
@@ -101,14 +360,21 @@ func parseHTML(html string, base *url.URL) ([]ContentBlock, []Link) {
 }
 
 type parseContext struct {
-	blocks  []ContentBlock
-	links   []Link
-	linkIdx int
-	base    *url.URL
+	blocks    []ContentBlock
+	links     []Link
+	linkIdx   int
+	linkIndex map[string]int  // footnote index already assigned to each URL
+	abbrs     map[string]bool // abbreviations whose expansion has been given
+	base      *url.URL
 }
 
+// resolveURL makes href absolute against the page URL. Protocol-relative
+// URLs (//host/path) take the page's scheme, or https without a page URL.
 func (ctx *parseContext) resolveURL(href string) string {
 	if ctx.base == nil {
+		if strings.HasPrefix(href, "//") {
+			return "https:" + href
+		}
 		return href
 	}
 	parsed, err := url.Parse(href)
@@ -118,6 +384,27 @@ func (ctx *parseContext) resolveURL(href string) string {
 	return ctx.base.ResolveReference(parsed).String()
 }
 
+// isFragmentLink reports whether href only points somewhere on the page
+// itself, like "#section". Readability has usually made hrefs absolute by now,
+// so a link back to the page URL with a fragment counts too.
+func (ctx *parseContext) isFragmentLink(href string) bool {
+	if strings.HasPrefix(href, "#") {
+		return true
+	}
+	if ctx.base == nil {
+		return false
+	}
+	parsed, err := url.Parse(href)
+	if err != nil || parsed.Fragment == "" {
+		return false
+	}
+	target := ctx.base.ResolveReference(parsed)
+	target.Fragment, target.RawFragment = "", ""
+	page := *ctx.base
+	page.Fragment, page.RawFragment = "", ""
+	return target.String() == page.String()
+}
+
 func (ctx *parseContext) extractBlocks(s *goquery.Selection) {
 	tagName := goquery.NodeName(s)
 
@@ -135,9 +422,9 @@ func (ctx *parseContext) extractBlocks(s *goquery.Selection) {
 		}
 
 	case tagName == "p":
-		// Extract any images inside the paragraph first
+		// Extract any images and embeds inside the paragraph first
 		s.Find("img").Each(func(_ int, img *goquery.Selection) {
-			src, _ := img.Attr("src")
+			src := imageSource(img)
 			alt, _ := img.Attr("alt")
 			if src != "" {
 				ctx.blocks = append(ctx.blocks, ContentBlock{
@@ -147,6 +434,9 @@ func (ctx *parseContext) extractBlocks(s *goquery.Selection) {
 				})
 			}
 		})
+		s.Find("video, audio, iframe").Each(func(_ int, media *goquery.Selection) {
+			ctx.extractEmbed(media)
+		})
 		text := ctx.extractTextWithLinks(s)
 		if text != "" {
 			ctx.blocks = append(ctx.blocks, ContentBlock{
@@ -156,57 +446,71 @@ func (ctx *parseContext) extractBlocks(s *goquery.Selection) {
 		}
 
 	case tagName == "pre":
+		// Take the text of the <code> inside only when it holds the whole
+		// listing; a <pre> can also mix plain text with inline <code>
 		code := s.Find("code")
-		var text string
-		if code.Length() > 0 {
+		text := s.Text()
+		if code.Length() > 0 && strings.TrimSpace(code.Text()) == strings.TrimSpace(text) {
 			text = code.Text()
-		} else {
-			text = s.Text()
 		}
-		text = strings.TrimRight(text, "\n\t ")
+		text = dedent(text)
 		if text != "" {
-			lang, _ := code.Attr("class")
-			lang = strings.TrimPrefix(lang, "language-")
 			ctx.blocks = append(ctx.blocks, ContentBlock{
 				Type:     BlockCode,
 				Text:     text,
-				Language: lang,
+				Language: codeLanguage(s, code),
 			})
 		}
 
 	case tagName == "ul" || tagName == "ol":
-		var items []string
+		var items, tasks []string
+		isTaskList := false
 		s.Find("li").Each(func(_ int, li *goquery.Selection) {
 			text := ctx.extractTextWithLinks(li)
 			if text != "" {
+				task, _ := li.Attr("data-task")
 				items = append(items, text)
+				tasks = append(tasks, task)
+				isTaskList = isTaskList || task != ""
 			}
 		})
+		if !isTaskList {
+			tasks = nil
+		}
 		if len(items) > 0 {
-			ctx.blocks = append(ctx.blocks, ContentBlock{
+			block := ContentBlock{
 				Type:    BlockList,
 				Items:   items,
 				Ordered: tagName == "ol",
-			})
+				Tasks:   tasks,
+			}
+			if block.Ordered {
+				block.Start, block.Numbering = listNumbering(s)
+			}
+			ctx.blocks = append(ctx.blocks, block)
 		}
 
 	case tagName == "blockquote":
-		text := ctx.extractTextWithLinks(s)
-		if text != "" {
-			ctx.blocks = append(ctx.blocks, ContentBlock{
-				Type: BlockQuote,
-				Text: text,
-			})
-		}
+		ctx.extractQuote(s, 1)
 
 	case tagName == "hr":
 		ctx.blocks = append(ctx.blocks, ContentBlock{Type: BlockHR})
 
 	case tagName == "figure":
-		img := s.Find("img")
-		if img.Length() > 0 {
+		// Code listings and embedded players are often wrapped in a figure
+		// with a caption
+		s.Find("pre").Each(func(_ int, pre *goquery.Selection) {
+			ctx.extractBlocks(pre)
+		})
+		s.Find("video, audio, iframe").Each(func(_ int, media *goquery.Selection) {
+			ctx.extractEmbed(media)
+		})
+		img := s.Find("img").First()
+		if img.Length() == 0 {
+			img = s.Find("picture").First()
+		}
+		if src := imageSource(img); src != "" {
 			alt, _ := img.Attr("alt")
-			src, _ := img.Attr("src")
 			ctx.blocks = append(ctx.blocks, ContentBlock{
 				Type: BlockImage,
 				Alt:  alt,
@@ -224,24 +528,78 @@ func (ctx *parseContext) extractBlocks(s *goquery.Selection) {
 			}
 		}
 
-	case tagName == "img":
-		alt, _ := s.Attr("alt")
-		src, _ := s.Attr("src")
-		ctx.blocks = append(ctx.blocks, ContentBlock{
-			Type: BlockImage,
-			Alt:  alt,
-			URL:  ctx.resolveURL(src),
-		})
+	case tagName == "img" || tagName == "picture":
+		img := s
+		if tagName == "picture" && s.Find("img").Length() > 0 {
+			img = s.Find("img").First()
+		}
+		alt, _ := img.Attr("alt")
+		if src := imageSource(img); src != "" {
+			ctx.blocks = append(ctx.blocks, ContentBlock{
+				Type: BlockImage,
+				Alt:  alt,
+				URL:  ctx.resolveURL(src),
+			})
+		}
+
+	case tagName == "video" || tagName == "audio" || tagName == "iframe":
+		ctx.extractEmbed(s)
 
 	case tagName == "table":
 		var rows [][]string
 		hasHeader := false
-		// Extract header rows from thead
-		s.Find("thead tr").Each(func(_ int, tr *goquery.Selection) {
+		// Column alignment comes from <col> elements or the first cell in
+		// each column that specifies one
+		var align []string
+		noteAlign := func(j int, cell *goquery.Selection) {
+			for len(align) <= j {
+				align = append(align, "")
+			}
+			if align[j] == "" {
+				align[j] = cellAlign(cell)
+			}
+		}
+		s.Find("col").Each(func(j int, col *goquery.Selection) {
+			noteAlign(j, col)
+		})
+		// Spanning cells are repeated in every grid position they cover, so
+		// columns stay aligned; carried holds rowspan cells still to be
+		// placed in later rows, keyed by column
+		carried := make(map[int]spannedCell)
+		readRow := func(tr *goquery.Selection) []string {
 			var row []string
-			tr.Find("th, td").Each(func(_ int, cell *goquery.Selection) {
-				row = append(row, cleanText(cell.Text()))
+			placeCarried := func() {
+				for {
+					c, ok := carried[len(row)]
+					if !ok {
+						return
+					}
+					if c.rows--; c.rows == 0 {
+						delete(carried, len(row))
+					} else {
+						carried[len(row)] = c
+					}
+					row = append(row, c.text)
+				}
+			}
+			tr.Find("td, th").Each(func(_ int, cell *goquery.Selection) {
+				placeCarried()
+				text := cleanText(cell.Text())
+				rowspan := spanAttr(cell, "rowspan", maxRowspan)
+				for range spanAttr(cell, "colspan", maxColspan) {
+					noteAlign(len(row), cell)
+					if rowspan > 1 {
+						carried[len(row)] = spannedCell{text: text, rows: rowspan - 1}
+					}
+					row = append(row, text)
+				}
 			})
+			placeCarried()
+			return row
+		}
+		// Extract header rows from thead
+		s.Find("thead tr").Each(func(_ int, tr *goquery.Selection) {
+			row := readRow(tr)
 			if len(row) > 0 {
 				rows = append(rows, row)
 				hasHeader = true
@@ -251,10 +609,7 @@ func (ctx *parseContext) extractBlocks(s *goquery.Selection) {
 		tbody := s.Find("tbody")
 		if tbody.Length() > 0 {
 			tbody.Find("tr").Each(func(_ int, tr *goquery.Selection) {
-				var row []string
-				tr.Find("td, th").Each(func(_ int, cell *goquery.Selection) {
-					row = append(row, cleanText(cell.Text()))
-				})
+				row := readRow(tr)
 				if len(row) > 0 {
 					rows = append(rows, row)
 				}
@@ -262,10 +617,7 @@ func (ctx *parseContext) extractBlocks(s *goquery.Selection) {
 		} else if s.Find("thead").Length() == 0 {
 			// No thead/tbody — all rows are direct children
 			s.Find("tr").Each(func(i int, tr *goquery.Selection) {
-				var row []string
-				tr.Find("td, th").Each(func(_ int, cell *goquery.Selection) {
-					row = append(row, cleanText(cell.Text()))
-				})
+				row := readRow(tr)
 				if len(row) > 0 {
 					// If first row is all <th>, treat as header
 					if i == 0 && tr.Find("th").Length() > 0 && tr.Find("td").Length() == 0 {
@@ -280,6 +632,31 @@ func (ctx *parseContext) extractBlocks(s *goquery.Selection) {
 				Type:   BlockTable,
 				Rows:   rows,
 				Header: hasHeader,
+				Align:  normalizeAlign(align),
+			})
+		}
+
+	case tagName == "details":
+		summary := s.ChildrenFiltered("summary").First()
+		if text := cleanText(summary.Text()); text != "" {
+			ctx.blocks = append(ctx.blocks, ContentBlock{
+				Type: BlockSummary,
+				Text: text,
+			})
+		}
+		if hasDirectText(s) {
+			// Bare text after the summary: the rest of the body is one paragraph
+			body := s.Clone()
+			body.ChildrenFiltered("summary").Remove()
+			if text := ctx.extractTextWithLinks(body); text != "" {
+				ctx.blocks = append(ctx.blocks, ContentBlock{
+					Type: BlockParagraph,
+					Text: text,
+				})
+			}
+		} else {
+			s.Children().Not("summary").Each(func(_ int, child *goquery.Selection) {
+				ctx.extractBlocks(child)
 			})
 		}
 
@@ -299,43 +676,524 @@ func (ctx *parseContext) extractBlocks(s *goquery.Selection) {
 	}
 }
 
+// extractEmbed adds a <video>, <audio> or <iframe> as an embed block, unless
+// it has no source to point at.
+func (ctx *parseContext) extractEmbed(s *goquery.Selection) {
+	src, _ := s.Attr("src")
+	if src == "" {
+		src, _ = s.Attr("data-src")
+	}
+	if src == "" {
+		// <video> and <audio> can list their sources as children instead
+		src, _ = s.Find("source[src]").First().Attr("src")
+	}
+	src = strings.TrimSpace(src)
+	if src == "" || src == "about:blank" || strings.HasPrefix(src, "data:") {
+		return
+	}
+
+	media := goquery.NodeName(s)
+	target := ctx.resolveURL(src)
+	if media == "iframe" {
+		media = MediaEmbed
+		if watch := watchURL(target); watch != "" {
+			media, target = MediaVideo, watch
+		}
+	}
+	title, _ := s.Attr("title")
+	ctx.blocks = append(ctx.blocks, ContentBlock{
+		Type:  BlockEmbed,
+		Media: media,
+		URL:   target,
+		Text:  cleanText(title),
+	})
+}
+
+// watchURL returns the page for watching the video shown by a YouTube or
+// Vimeo player embed URL, or "" for other URLs.
+func watchURL(embedURL string) string {
+	u, err := url.Parse(embedURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case (host == "youtube.com" || host == "youtube-nocookie.com") &&
+		len(segments) == 2 && segments[0] == "embed" && segments[1] != "videoseries":
+		return "https://www.youtube.com/watch?v=" + segments[1]
+	case host == "player.vimeo.com" && len(segments) == 2 && segments[0] == "video":
+		if hash := u.Query().Get("h"); hash != "" {
+			// Unlisted videos need their hash in the page URL too
+			return "https://vimeo.com/" + segments[1] + "/" + hash
+		}
+		return "https://vimeo.com/" + segments[1]
+	}
+	return ""
+}
+
 // extractTextWithLinks walks the DOM tree and replaces <a> tags with
 // "link text [N]" where N is a footnote index, collecting the URL.
 func (ctx *parseContext) extractTextWithLinks(s *goquery.Selection) string {
+	return ctx.inlineText(s.Contents())
+}
+
+// extractQuote adds a blockquote as quote blocks of the given depth. Text on
+// either side of a nested blockquote becomes its own block, and the nested
+// quote is added one level deeper, so replies quoting replies keep their shape.
+// Paragraphs within a block are separated by a blank line.
+func (ctx *parseContext) extractQuote(s *goquery.Selection, depth int) {
+	contents := s.Contents()
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			if text := strings.Join(ctx.quoteParagraphs(contents.Slice(start, end)), "\n\n"); text != "" {
+				ctx.blocks = append(ctx.blocks, ContentBlock{
+					Type:  BlockQuote,
+					Text:  text,
+					Level: depth,
+				})
+			}
+		}
+		start = end + 1
+	}
+
+	contents.Each(func(i int, child *goquery.Selection) {
+		switch {
+		case goquery.NodeName(child) == "blockquote":
+			flush(i)
+			ctx.extractQuote(child, depth+1)
+		case child.Find("blockquote").Length() > 0:
+			// A wrapper such as a <div> around the nested quote
+			flush(i)
+			ctx.extractQuote(child, depth)
+		}
+	})
+	flush(contents.Length())
+}
+
+// quoteBlockElements are the elements that start a new paragraph in a quote.
+var quoteBlockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "header": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "pre": true, "figure": true, "figcaption": true,
+	"dl": true, "dt": true, "dd": true, "table": true, "tr": true,
+}
+
+// quoteParagraphs splits the contents of a quote into paragraphs. Each block
+// element is one, or several when it holds more block elements itself, and
+// loose inline content between them is another.
+func (ctx *parseContext) quoteParagraphs(nodes *goquery.Selection) []string {
+	var paras []string
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			if text := ctx.inlineText(nodes.Slice(start, end)); text != "" {
+				paras = append(paras, text)
+			}
+		}
+		start = end + 1
+	}
+
+	nodes.Each(func(i int, child *goquery.Selection) {
+		if quoteBlockElements[goquery.NodeName(child)] {
+			flush(i)
+			paras = append(paras, ctx.quoteParagraphs(child.Contents())...)
+		}
+	})
+	flush(nodes.Length())
+	return paras
+}
+
+// inlineText renders nodes as inline text: link references, <br> breaks and
+// formatting marks.
+func (ctx *parseContext) inlineText(nodes *goquery.Selection) string {
 	var b strings.Builder
-	s.Contents().Each(func(_ int, child *goquery.Selection) {
+	nodes.Each(func(_ int, child *goquery.Selection) {
 		if goquery.NodeName(child) == "a" {
 			href, exists := child.Attr("href")
 			text := cleanText(child.Text())
 			if text == "" {
 				return
 			}
-			if exists && href != "" && href != "#" {
-				ctx.linkIdx++
-				ctx.links = append(ctx.links, Link{
-					Index: ctx.linkIdx,
-					Text:  text,
-					URL:   ctx.resolveURL(href),
-				})
+			if exists && href != "" && !ctx.isFragmentLink(href) {
 				b.WriteString(text)
-				b.WriteString(fmt.Sprintf(" [%d]", ctx.linkIdx))
+				title, _ := child.Attr("title")
+				b.WriteString(fmt.Sprintf(" [%d]", ctx.linkFor(ctx.resolveURL(href), text, cleanText(title))))
 			} else {
 				b.WriteString(text)
 			}
 		} else if goquery.NodeName(child) == "#text" {
-			b.WriteString(child.Text())
+			// Source newlines are just whitespace; only <br> forces a break
+			b.WriteString(strings.Map(func(r rune) rune {
+				if r == '\n' || r == '\r' {
+					return ' '
+				}
+				return r
+			}, child.Text()))
+		} else if goquery.NodeName(child) == "br" {
+			b.WriteByte('\n')
+		} else if name := goquery.NodeName(child); (name == "sup" || name == "sub") && child.Find("a").Length() == 0 {
+			if name == "sup" {
+				writeScript(&b, child.Text(), ctx.extractTextWithLinks(child), superscripts, "^")
+			} else {
+				writeScript(&b, child.Text(), ctx.extractTextWithLinks(child), subscripts, "_")
+			}
+		} else if name == "abbr" {
+			title, _ := child.Attr("title")
+			ctx.writeAbbr(&b, child.Text(), ctx.extractTextWithLinks(child), cleanText(title))
+		} else if name == "kbd" && child.Find("kbd").Length() > 0 {
+			// A key combination like <kbd><kbd>Ctrl</kbd>+<kbd>C</kbd></kbd>:
+			// mark each key rather than the whole combination
+			b.WriteString(ctx.extractTextWithLinks(child))
+		} else if marks, ok := inlineMarks[name]; ok {
+			writeMarked(&b, child.Text(), ctx.extractTextWithLinks(child), marks)
 		} else {
 			// Recurse into other inline elements (em, strong, span, etc.)
 			b.WriteString(ctx.extractTextWithLinks(child))
 		}
 	})
-	result := b.String()
-	fields := strings.Fields(decodeEntities(result))
-	return strings.Join(fields, " ")
+	return cleanLines(plainSpaces(b.String()))
+}
+
+// hasDirectText reports whether s has a non-blank text node as a direct child.
+func hasDirectText(s *goquery.Selection) bool {
+	found := false
+	s.Contents().Each(func(_ int, child *goquery.Selection) {
+		if goquery.NodeName(child) == "#text" && strings.TrimSpace(child.Text()) != "" {
+			found = true
+		}
+	})
+	return found
+}
+
+// linkFor returns the footnote index for url, adding it to the links list the
+// first time it appears so repeated links share one footnote.
+func (ctx *parseContext) linkFor(url, text, title string) int {
+	if idx, ok := ctx.linkIndex[url]; ok {
+		return idx
+	}
+	ctx.linkIdx++
+	ctx.linkIndex[url] = ctx.linkIdx
+	ctx.links = append(ctx.links, Link{
+		Index: ctx.linkIdx,
+		Text:  text,
+		URL:   url,
+		Title: title,
+	})
+	return ctx.linkIdx
+}
+
+// languageAliases maps short language names to the ones chroma knows best.
+var languageAliases = map[string]string{
+	"js":      "javascript",
+	"jsx":     "javascript",
+	"ts":      "typescript",
+	"py":      "python",
+	"py3":     "python",
+	"rb":      "ruby",
+	"sh":      "bash",
+	"shell":   "bash",
+	"zsh":     "bash",
+	"console": "bash",
+	"yml":     "yaml",
+	"golang":  "go",
+	"c++":     "cpp",
+	"cs":      "csharp",
+	"c#":      "csharp",
+	"md":      "markdown",
+	"rs":      "rust",
+	"kt":      "kotlin",
+}
+
+// languageClassPrefixes introduce a language name in a class attribute.
+var languageClassPrefixes = []string{"language-", "lang-", "highlight-source-", "highlight-"}
+
+// codeLanguage works out the language of a <pre> block: a data-lang or
+// data-language attribute, or a language-*, lang-*, highlight-* or
+// "brush: *" class, looked for on the <code>, the <pre>, the <pre>'s parent
+// and an enclosing <figure>. It returns "" when none says.
+func codeLanguage(pre, code *goquery.Selection) string {
+	candidates := []*goquery.Selection{code, pre, pre.Parent(), pre.Closest("figure")}
+	for _, el := range candidates {
+		if el.Length() == 0 {
+			continue
+		}
+		for _, attr := range []string{"data-lang", "data-language"} {
+			if v, _ := el.Attr(attr); strings.TrimSpace(v) != "" {
+				return normalizeLanguage(v)
+			}
+		}
+	}
+	for _, el := range candidates {
+		if el.Length() == 0 {
+			continue
+		}
+		class, _ := el.Attr("class")
+		// SyntaxHighlighter style: class="brush: js; gutter: false"
+		if _, after, ok := strings.Cut(class, "brush:"); ok {
+			lang, _, _ := strings.Cut(after, ";")
+			if fields := strings.Fields(lang); len(fields) > 0 {
+				return normalizeLanguage(fields[0])
+			}
+		}
+		for _, name := range strings.Fields(class) {
+			for _, prefix := range languageClassPrefixes {
+				if lang, ok := strings.CutPrefix(name, prefix); ok && lang != "" {
+					return normalizeLanguage(lang)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeLanguage lowercases a language name and maps common aliases.
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if alias, ok := languageAliases[lang]; ok {
+		return alias
+	}
+	return lang
+}
+
+// dedent removes the leading whitespace every non-blank line of a code block
+// shares, keeping relative indentation. Blank lines at either end are dropped;
+// blank lines inside are kept, emptied of stray whitespace.
+func dedent(text string) string {
+	lines := strings.Split(text, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var prefix string
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		n := 0
+		for n < len(prefix) && n < len(indent) && prefix[n] == indent[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+		} else {
+			lines[i] = line[len(prefix):]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cleanLines collapses whitespace within each line but keeps the line breaks
+// produced by <br>, dropping blank lines at either end.
+func cleanLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// imageSource picks the URL to load for an <img> (or a <picture> without one):
+// its src, then the lazy-load attributes data-src and data-original, then the
+// largest srcset candidate on the image or on a <source> in its <picture>.
+// Inline data: URLs are treated as lazy-load placeholders and skipped.
+func imageSource(img *goquery.Selection) string {
+	for _, attr := range []string{"src", "data-src", "data-original"} {
+		if v, _ := img.Attr(attr); v != "" && !strings.HasPrefix(v, "data:") {
+			return strings.TrimSpace(v)
+		}
+	}
+	sets := []*goquery.Selection{img}
+	img.Closest("picture").Find("source").Each(func(_ int, source *goquery.Selection) {
+		sets = append(sets, source)
+	})
+	for _, s := range sets {
+		for _, attr := range []string{"srcset", "data-srcset"} {
+			if v, _ := s.Attr(attr); v != "" {
+				if src := largestSrcset(v); src != "" {
+					return src
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// largestSrcset returns the candidate with the largest width (or pixel
+// density) descriptor from a srcset value, or the first one if none has a
+// descriptor.
+func largestSrcset(srcset string) string {
+	best := ""
+	bestSize := 0.0
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "data:") {
+			continue
+		}
+		size := 1.0
+		if len(fields) > 1 {
+			desc := fields[1]
+			if n, err := strconv.ParseFloat(desc[:len(desc)-1], 64); err == nil && (strings.HasSuffix(desc, "w") || strings.HasSuffix(desc, "x")) {
+				size = n
+			}
+		}
+		if best == "" || size > bestSize {
+			best, bestSize = fields[0], size
+		}
+	}
+	return best
+}
+
+// annotateForReadability copies what readability would strip but we still
+// need into data-* attributes: the alignment of table cells and columns
+// (data-align), task-list checkboxes on list items (data-task) and the
+// language of code blocks, which is often only in a class (data-lang).
+func annotateForReadability(rawHTML []byte) []byte {
+	if !bytes.Contains(rawHTML, []byte("align")) && !bytes.Contains(rawHTML, []byte("checkbox")) &&
+		!bytes.Contains(rawHTML, []byte("<pre")) {
+		return rawHTML
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(rawHTML))
+	if err != nil {
+		return rawHTML
+	}
+	changed := false
+	doc.Find("th, td, col").Each(func(_ int, s *goquery.Selection) {
+		if align := cellAlign(s); align != "" {
+			s.SetAttr("data-align", align)
+			changed = true
+		}
+	})
+	doc.Find("li").Each(func(_ int, li *goquery.Selection) {
+		if box := leadingCheckbox(li); box != nil {
+			state := TaskUnchecked
+			if _, checked := box.Attr("checked"); checked {
+				state = TaskChecked
+			}
+			li.SetAttr("data-task", state)
+			changed = true
+		}
+	})
+	doc.Find("pre").Each(func(_ int, pre *goquery.Selection) {
+		if lang := codeLanguage(pre, pre.Find("code").First()); lang != "" {
+			pre.SetAttr("data-lang", lang)
+			changed = true
+		}
+	})
+	if !changed {
+		return rawHTML
+	}
+	out, err := doc.Html()
+	if err != nil {
+		return rawHTML
+	}
+	return []byte(out)
+}
+
+// leadingCheckbox returns the checkbox <input> that starts a list item, looking
+// inside a leading <p> or <label>, or nil if the item doesn't start with one.
+func leadingCheckbox(s *goquery.Selection) *goquery.Selection {
+	var box *goquery.Selection
+	s.Contents().EachWithBreak(func(_ int, child *goquery.Selection) bool {
+		switch goquery.NodeName(child) {
+		case "#text":
+			// Keep looking past whitespace only
+			return strings.TrimSpace(child.Text()) == ""
+		case "input":
+			if t, _ := child.Attr("type"); strings.EqualFold(t, "checkbox") {
+				box = child
+			}
+		case "p", "label":
+			box = leadingCheckbox(child)
+		}
+		return false
+	})
+	return box
+}
+
+// Limits on colspan and rowspan, as in the HTML spec, so a bogus attribute
+// can't blow up the table grid.
+const (
+	maxColspan = 1000
+	maxRowspan = 65534
+)
+
+// spannedCell is a table cell carried down into the rows its rowspan covers.
+type spannedCell struct {
+	text string
+	rows int // rows still to fill
+}
+
+// spanAttr reads a colspan or rowspan attribute, treating missing, invalid
+// and zero values as 1 and clamping large ones to limit.
+func spanAttr(cell *goquery.Selection, name string, limit int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(cell.AttrOr(name, "")))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return min(n, limit)
+}
+
+// cellAlign reads the horizontal alignment of a table cell or <col> from its
+// data-align or align attribute or its text-align style, returning "" when
+// none is set.
+func cellAlign(s *goquery.Selection) string {
+	value, ok := s.Attr("data-align")
+	if !ok {
+		value, _ = s.Attr("align")
+	}
+	if style, ok := s.Attr("style"); ok {
+		for _, decl := range strings.Split(style, ";") {
+			prop, v, found := strings.Cut(decl, ":")
+			if found && strings.EqualFold(strings.TrimSpace(prop), "text-align") {
+				value = v
+			}
+		}
+	}
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "left", "center", "right":
+		return value
+	case "start":
+		return "left"
+	case "end":
+		return "right"
+	}
+	return ""
+}
+
+// normalizeAlign fills unspecified columns with "left", or returns nil if no
+// column specified an alignment.
+func normalizeAlign(align []string) []string {
+	specified := false
+	for i, a := range align {
+		if a == "" {
+			align[i] = "left"
+		} else {
+			specified = true
+		}
+	}
+	if !specified {
+		return nil
+	}
+	return align
 }
 
 func cleanText(s string) string {
-	s = decodeEntities(s)
+	s = plainSpaces(s)
 	fields := strings.Fields(s)
 	return strings.Join(fields, " ")
 }
@@ -377,26 +1235,135 @@ func extractMetaDescription(rawHTML []byte) string {
 	return ""
 }
 
-func decodeEntities(s string) string {
-	replacer := strings.NewReplacer(
-		"&amp;", "&",
-		"&lt;", "<",
-		"&gt;", ">",
-		"&quot;", `"`,
-		"&#39;", "'",
-		"&apos;", "'",
-		"&#x27;", "'",
-		"&nbsp;", " ",
-		"&#160;", " ",
-		"&mdash;", "\u2014",
-		"&ndash;", "\u2013",
-		"&hellip;", "\u2026",
-		"&laquo;", "\u00ab",
-		"&raquo;", "\u00bb",
-		"&ldquo;", "\u201c",
-		"&rdquo;", "\u201d",
-		"&lsquo;", "\u2018",
-		"&rsquo;", "\u2019",
-	)
-	return replacer.Replace(s)
+// extractLeadImage returns the URL and alt text of the page's og:image (or
+// Twitter card image), resolved against base. fallback is the image
+// readability found in the metadata, used when there is no such tag.
+func extractLeadImage(rawHTML []byte, fallback string, base *url.URL) (src, alt string) {
+	if doc, err := goquery.NewDocumentFromReader(bytes.NewReader(rawHTML)); err == nil {
+		for _, sel := range []string{`meta[property="og:image"]`, `meta[property="og:image:url"]`,
+			`meta[property="og:image:secure_url"]`, `meta[name="twitter:image"]`} {
+			if v, _ := doc.Find(sel).First().Attr("content"); strings.TrimSpace(v) != "" {
+				src = strings.TrimSpace(v)
+				break
+			}
+		}
+		for _, sel := range []string{`meta[property="og:image:alt"]`, `meta[name="twitter:image:alt"]`} {
+			if v, _ := doc.Find(sel).First().Attr("content"); v != "" {
+				alt = cleanText(v)
+				break
+			}
+		}
+	}
+	if src == "" {
+		src = strings.TrimSpace(fallback)
+	}
+	if src == "" || strings.HasPrefix(src, "data:") {
+		return "", ""
+	}
+	if base != nil {
+		if ref, err := url.Parse(src); err == nil {
+			src = base.ResolveReference(ref).String()
+		}
+	}
+	return src, alt
+}
+
+// faviconRels are the <link rel> values that name a site icon, best first.
+var faviconRels = []string{"icon", "shortcut icon", "apple-touch-icon", "apple-touch-icon-precomposed"}
+
+// extractFavicon returns the URL of the page's icon from its <link> tags,
+// falling back to /favicon.ico on the page's host.
+func extractFavicon(rawHTML []byte, base *url.URL) string {
+	if base == nil || base.Host == "" {
+		return ""
+	}
+	if doc, err := goquery.NewDocumentFromReader(bytes.NewReader(rawHTML)); err == nil {
+		icons := make(map[string]string)
+		doc.Find("link[rel][href]").Each(func(_ int, link *goquery.Selection) {
+			rel, _ := link.Attr("rel")
+			rel = strings.Join(strings.Fields(strings.ToLower(rel)), " ")
+			href, _ := link.Attr("href")
+			if href = strings.TrimSpace(href); href != "" && !strings.HasPrefix(href, "data:") && icons[rel] == "" {
+				icons[rel] = href
+			}
+		})
+		for _, rel := range faviconRels {
+			if href := icons[rel]; href != "" {
+				if ref, err := url.Parse(href); err == nil {
+					return base.ResolveReference(ref).String()
+				}
+			}
+		}
+	}
+	return (&url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/favicon.ico"}).String()
+}
+
+// RedirectTarget returns the page a document points readers to instead of
+// itself: the URL of a <meta http-equiv="refresh">, or else its
+// <link rel="canonical">, resolved against pageURL. It returns "" when there
+// is neither or the target is pageURL itself.
+func RedirectTarget(rawHTML []byte, pageURL string) string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(rawHTML))
+	if err != nil {
+		return ""
+	}
+
+	target := ""
+	doc.Find("meta[http-equiv]").EachWithBreak(func(_ int, meta *goquery.Selection) bool {
+		equiv, _ := meta.Attr("http-equiv")
+		if !strings.EqualFold(equiv, "refresh") {
+			return true
+		}
+		content, _ := meta.Attr("content")
+		target = refreshURL(content)
+		return target == ""
+	})
+	if target == "" {
+		target, _ = doc.Find(`link[rel="canonical"]`).Attr("href")
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return ""
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	resolved := base.ResolveReference(ref)
+	resolved.Fragment = ""
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return ""
+	}
+	if resolved.String() == pageURL {
+		return ""
+	}
+	return resolved.String()
+}
+
+// refreshURL extracts the URL from a meta refresh content value such as
+// "0;url=https://example.com/" or "5; URL='/next'".
+func refreshURL(content string) string {
+	_, rest, ok := strings.Cut(content, ";")
+	if !ok {
+		return ""
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) >= 4 && strings.EqualFold(rest[:3], "url") {
+		if after := strings.TrimSpace(rest[3:]); strings.HasPrefix(after, "=") {
+			rest = strings.TrimSpace(after[1:])
+		}
+	}
+	return strings.Trim(rest, `"'`)
+}
+
+// plainSpaces turns non-breaking spaces into ordinary ones, so they collapse
+// like any other. Entities need no decoding: the HTML parser has done that,
+// and decoding again would turn a literal "&amp;lt;" into "<".
+func plainSpaces(s string) string {
+	return strings.ReplaceAll(s, "\u00a0", " ")
 }
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRepeatedLinkSharesFootnote(t *testing.T) {
+	base, _ := url.Parse("https://example.com/post")
+	blocks, links := parseHTML(`<html><body>
+<p>See <a href="/docs">the docs</a> and <a href="https://other.example/">elsewhere</a>.</p>
+<p>Again, <a href="https://example.com/docs">the documentation</a>.</p>
+</body></html>`, base, nil)
+
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2: %+v", len(blocks), blocks)
+	}
+	if want := "See the docs [1] and elsewhere [2]."; blocks[0].Text != want {
+		t.Errorf("first paragraph = %q, want %q", blocks[0].Text, want)
+	}
+	if want := "Again, the documentation [1]."; blocks[1].Text != want {
+		t.Errorf("second paragraph = %q, want %q", blocks[1].Text, want)
+	}
+
+	want := []Link{
+		{Index: 1, Text: "the docs", URL: "https://example.com/docs"},
+		{Index: 2, Text: "elsewhere", URL: "https://other.example/"},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("got links %+v, want %+v", links, want)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Errorf("links[%d] = %+v, want %+v", i, links[i], want[i])
+		}
+	}
+}
+
+func TestTableColspanHeader(t *testing.T) {
+	article, err := Parse([]byte(`<html><body><article>
+<table>
+<thead><tr><th>Name</th><th colspan="2">Size</th></tr></thead>
+<tbody><tr><td>a</td><td>1</td><td>2</td></tr></tbody>
+</table>
+</article></body></html>`), "https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var table *ContentBlock
+	for i := range article.Content {
+		if article.Content[i].Type == BlockTable {
+			table = &article.Content[i]
+		}
+	}
+	if table == nil {
+		t.Fatalf("no table in %+v", article.Content)
+	}
+	if !table.Header {
+		t.Error("table has no header row")
+	}
+	want := [][]string{{"Name", "Size", "Size"}, {"a", "1", "2"}}
+	if !reflect.DeepEqual(table.Rows, want) {
+		t.Errorf("rows = %q, want %q", table.Rows, want)
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	base, _ := url.Parse("http://example.com/blog/post?page=2")
+	tests := []struct {
+		base *url.URL
+		href string
+		want string
+	}{
+		{base, "//cdn.example.net/img.png", "http://cdn.example.net/img.png"},
+		{nil, "//cdn.example.net/img.png", "https://cdn.example.net/img.png"},
+		{base, "#top", "http://example.com/blog/post?page=2#top"},
+		{base, "?q=1", "http://example.com/blog/post?q=1"},
+		{base, "../x", "http://example.com/x"},
+		{base, "https://other.example/a", "https://other.example/a"},
+	}
+	for _, tt := range tests {
+		ctx := &parseContext{base: tt.base}
+		if got := ctx.resolveURL(tt.href); got != tt.want {
+			t.Errorf("resolveURL(%q) against %v = %q, want %q", tt.href, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestFragmentLinksSkipped(t *testing.T) {
+	base, _ := url.Parse("http://example.com/blog/post?page=2")
+	blocks, links := parseHTML(`<html><body>
+<p>Back to <a href="#top">Top</a>, <a href="#">up</a> or <a href="http://example.com/blog/post?page=2#x">here</a>.</p>
+<p>Unlike <a href="/blog/other#x">another post</a> or <a href="?page=3#top">the next page</a>.</p>
+</body></html>`, base, nil)
+
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2: %+v", len(blocks), blocks)
+	}
+	if want := "Back to Top, up or here."; blocks[0].Text != want {
+		t.Errorf("first paragraph = %q, want %q", blocks[0].Text, want)
+	}
+	if want := "Unlike another post [1] or the next page [2]."; blocks[1].Text != want {
+		t.Errorf("second paragraph = %q, want %q", blocks[1].Text, want)
+	}
+	want := []Link{
+		{Index: 1, Text: "another post", URL: "http://example.com/blog/other#x"},
+		{Index: 2, Text: "the next page", URL: "http://example.com/blog/post?page=3#top"},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("links = %+v, want %+v", links, want)
+	}
+}
+
+func TestPreKeepsEscapedMarkup(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"plain", "<pre>&lt;div&gt;\n  hello\n&lt;/div&gt;</pre>", "<div>\n  hello\n</div>"},
+		{"code", "<pre><code>&lt;div class=\"a\"&gt;&amp;amp;&lt;/div&gt;</code></pre>", `<div class="a">&amp;</div>`},
+		{"mixed", "<pre>$ cat page.html\n<code>&lt;div&gt;hi&lt;/div&gt;</code></pre>", "$ cat page.html\n<div>hi</div>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks, _ := parseHTML("<html><body>"+tt.html+"</body></html>", nil, nil)
+			if len(blocks) != 1 || blocks[0].Type != BlockCode {
+				t.Fatalf("got %+v, want one code block", blocks)
+			}
+			if blocks[0].Text != tt.want {
+				t.Errorf("text = %q, want %q", blocks[0].Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntitiesDecodedOnce(t *testing.T) {
+	blocks, links := parseHTML(`<html><body>
+<h2>Escaping &amp;amp; in HTML</h2>
+<p>Write &amp;lt;div&amp;gt; to show &lt;div&gt;, and&nbsp;&copy; is &#169;. See <a href="/e" title="&amp;quot;q&amp;quot;">&amp;amp;</a>.</p>
+</body></html>`, nil, nil)
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2: %+v", len(blocks), blocks)
+	}
+	if want := "Escaping &amp; in HTML"; blocks[0].Text != want {
+		t.Errorf("heading = %q, want %q", blocks[0].Text, want)
+	}
+	if want := "Write &lt;div&gt; to show <div>, and © is ©. See &amp; [1]."; blocks[1].Text != want {
+		t.Errorf("paragraph = %q, want %q", blocks[1].Text, want)
+	}
+	if len(links) != 1 || links[0].Text != "&amp;" || links[0].Title != "&quot;q&quot;" {
+		t.Errorf("links = %+v, want text %q and title %q", links, "&amp;", "&quot;q&quot;")
+	}
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that only identify where a click came
+// from. Anything not listed here is kept, since it may select the page.
+var trackingParams = map[string]bool{
+	"fbclid":      true, // Facebook
+	"gclid":       true, // Google Ads
+	"dclid":       true,
+	"gbraid":      true,
+	"wbraid":      true,
+	"msclkid":     true, // Microsoft Ads
+	"yclid":       true, // Yandex
+	"twclid":      true, // Twitter/X
+	"igshid":      true, // Instagram
+	"mc_cid":      true, // Mailchimp
+	"mc_eid":      true,
+	"_hsenc":      true, // HubSpot
+	"_hsmi":       true,
+	"mkt_tok":     true, // Marketo
+	"oly_anon_id": true, // Omeda
+	"oly_enc_id":  true,
+	"vero_id":     true,
+	"vero_conv":   true,
+}
+
+// trackingPrefixes are families of tracking parameters, e.g. utm_source.
+var trackingPrefixes = []string{"utm_"}
+
+// StripTrackingParams removes known tracking query parameters from the URLs
+// of article's links.
+func StripTrackingParams(article *Article) {
+	for i := range article.Links {
+		article.Links[i].URL = StripTracking(article.Links[i].URL)
+	}
+}
+
+// StripTracking returns rawURL without known tracking query parameters. The
+// remaining parameters keep their order and encoding, and the fragment is
+// left alone.
+func StripTracking(rawURL string) string {
+	rest, fragment, hasFragment := strings.Cut(rawURL, "#")
+	base, query, hasQuery := strings.Cut(rest, "?")
+	if !hasQuery {
+		return rawURL
+	}
+
+	var kept []string
+	for _, param := range strings.Split(query, "&") {
+		key, _, _ := strings.Cut(param, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if !isTrackingParam(strings.ToLower(key)) {
+			kept = append(kept, param)
+		}
+	}
+
+	out := base
+	if len(kept) > 0 {
+		out += "?" + strings.Join(kept, "&")
+	}
+	if hasFragment {
+		out += "#" + fragment
+	}
+	return out
+}
+
+func isTrackingParam(key string) bool {
+	if trackingParams[key] {
+		return true
+	}
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
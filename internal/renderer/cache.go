@@ -0,0 +1,113 @@
+package renderer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// imageCacheTTL is how long a cached image is served before refetching.
+const imageCacheTTL = 24 * time.Hour
+
+// imageCacheDir returns $XDG_CACHE_HOME/getwebsite/images, falling back to
+// ~/.cache when XDG_CACHE_HOME is unset.
+func imageCacheDir() string {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		base = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(base, "getwebsite", "images")
+}
+
+// imageCacheKey hashes a URL and the identity of the fetcher it was
+// downloaded with into a stable cache file name (without extension), so an
+// image fetched with cookies or credentials is never served to a run without
+// them.
+func imageCacheKey(url, identity string) string {
+	sum := sha256.Sum256([]byte(url + "\x00" + identity))
+	return hex.EncodeToString(sum[:])
+}
+
+// readCachedImage returns cached bytes for url, as fetched with identity, if
+// a fresh copy exists.
+func readCachedImage(url, identity string) ([]byte, bool) {
+	dir := imageCacheDir()
+	if dir == "" {
+		return nil, false
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, imageCacheKey(url, identity)+"*"))
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) > imageCacheTTL {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err == nil && len(data) > 0 {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// writeCachedImage stores image bytes, naming the file with an extension
+// derived from the content type so cached files can be inspected. It writes
+// through a temporary file so a concurrent run never reads half an image.
+// Images may have been fetched with credentials, so the directory is private
+// and the files, made by os.CreateTemp, are 0600.
+func writeCachedImage(url, identity, contentType string, data []byte) {
+	dir := imageCacheDir()
+	if dir == "" || len(data) == 0 {
+		return
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+
+	key := imageCacheKey(url, identity)
+	// Drop stale copies saved under a different extension
+	old, _ := filepath.Glob(filepath.Join(dir, key+"*"))
+	for _, path := range old {
+		os.Remove(path)
+	}
+	// The temporary name doesn't start with the key, so readers never match it
+	tmp, err := os.CreateTemp(dir, ".tmp-"+key+"-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(tmp.Name(), filepath.Join(dir, key+imageExtension(contentType))) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// imageExtension maps an image content type to a file extension.
+func imageExtension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
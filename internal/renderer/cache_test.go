@@ -0,0 +1,43 @@
+package renderer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImageCacheIsPrivatePerIdentity(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	const url = "https://example.com/private.png"
+
+	writeCachedImage(url, "with cookies", "image/png", []byte("png"))
+	if data, ok := readCachedImage(url, "with cookies"); !ok || string(data) != "png" {
+		t.Errorf("read back %q, %v; want the cached image", data, ok)
+	}
+	if _, ok := readCachedImage(url, ""); ok {
+		t.Error("an image cached with cookies was served to a fetcher without them")
+	}
+
+	dir := imageCacheDir()
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("cache dir mode = %o, want 700", perm)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("cache holds %v, want one file", files)
+	}
+	info, err = os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("cached file mode = %o, want 600", perm)
+	}
+	if filepath.Ext(files[0]) != ".png" {
+		t.Errorf("cached file %s has no .png extension", files[0])
+	}
+}
@@ -0,0 +1,26 @@
+package renderer
+
+import (
+	"encoding/csv"
+	"strings"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+)
+
+// RenderCSV converts the article's tables to CSV, separated by blank lines.
+// It returns "" if the article has no tables.
+func RenderCSV(article *parser.Article) string {
+	var b strings.Builder
+	for _, block := range article.Content {
+		if block.Type != parser.BlockTable || len(block.Rows) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		w := csv.NewWriter(&b)
+		// Writing to a strings.Builder can't fail
+		_ = w.WriteAll(block.Rows)
+	}
+	return b.String()
+}
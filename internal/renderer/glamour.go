@@ -0,0 +1,83 @@
+package renderer
+
+import (
+	"strings"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+// glamourLinks is how RenderMarkdown starts its Links section.
+const glamourLinks = "---\n\n## Links\n\n"
+
+// renderGlamour renders the article as RenderMarkdown writes it, through
+// glamour at the renderer's width. Glamour doesn't say where it put things,
+// so HeadingLines is found by looking for each heading's text in order.
+func (r *Renderer) renderGlamour(article *parser.Article) (string, error) {
+	tr, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(r.glamourStyle()),
+		glamour.WithColorProfile(lipgloss.ColorProfile()),
+		glamour.WithWordWrap(r.width),
+	)
+	if err != nil {
+		return "", err
+	}
+	// The links are reference definitions, which glamour shows after each
+	// [N] in the text rather than as a list, so the section heading would
+	// be left over with nothing under it
+	md := RenderMarkdown(article, MarkdownOptions{})
+	if i := strings.LastIndex(md, glamourLinks); i >= 0 {
+		md = md[:i] + md[i+len(glamourLinks):]
+	}
+	out, err := tr.Render(md)
+	if err != nil {
+		return "", err
+	}
+
+	r.HeadingLines, r.LinkLines = nil, nil
+	lines := strings.Split(out, "\n")
+	// Start below the title, which may share its text with a heading
+	next := 0
+	if i := headingLine(lines, 0, article.Title); i >= 0 {
+		next = i + 1
+	}
+	for _, block := range article.Content {
+		if block.Type != parser.BlockHeading {
+			continue
+		}
+		if i := headingLine(lines, next, block.Text); i >= 0 {
+			r.HeadingLines = append(r.HeadingLines, i)
+			next = i + 1
+		}
+	}
+	return out, nil
+}
+
+// headingLine returns the index of the first of lines, from start on, that
+// begins heading text, which may be wrapped onto the lines after it, or -1.
+func headingLine(lines []string, start int, text string) int {
+	for i := start; i < len(lines); i++ {
+		line := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(ansi.Strip(lines[i])), "#"))
+		if line != "" && strings.HasPrefix(text, line) {
+			return i
+		}
+	}
+	return -1
+}
+
+// glamourStyle picks the glamour style closest to the renderer's theme: no
+// colors at all when the terminal has none or the theme is mono.
+func (r *Renderer) glamourStyle() string {
+	switch {
+	case lipgloss.ColorProfile() == termenv.Ascii || r.theme.Name == "mono":
+		return styles.NoTTYStyle
+	case r.theme.Name == "light":
+		return styles.LightStyle
+	default:
+		return styles.DarkStyle
+	}
+}
@@ -0,0 +1,277 @@
+package renderer
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+)
+
+// htmlStyle is a small embedded stylesheet so exported pages read well offline.
+const htmlStyle = `body { max-width: 42em; margin: 2em auto; padding: 0 1em; font-family: Georgia, serif; line-height: 1.6; color: #222; }
+pre { background: #f4f4f4; padding: 1em; overflow-x: auto; }
+code { font-family: Menlo, Consolas, monospace; font-size: 0.9em; }
+blockquote { border-left: 3px solid #ccc; margin-left: 0; padding-left: 1em; color: #555; font-style: italic; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; }
+img { max-width: 100%; }
+.meta { color: #777; }`
+
+// RenderHTML converts an Article to a self-contained HTML document.
+func RenderHTML(article *parser.Article) string {
+	var b strings.Builder
+
+	links := make(map[int]string, len(article.Links))
+	for _, link := range article.Links {
+		links[link.Index] = link.URL
+	}
+	text := func(s string) string {
+		escaped := linkifyRefs(html.EscapeString(s), links)
+		return strings.ReplaceAll(htmlInline(escaped), "\n", "<br>\n")
+	}
+
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
+	b.WriteString("<meta charset=\"utf-8\">\n")
+	b.WriteString("<title>" + html.EscapeString(article.Title) + "</title>\n")
+	if article.FaviconURL != "" {
+		b.WriteString("<link rel=\"icon\" href=\"" + html.EscapeString(article.FaviconURL) + "\">\n")
+	}
+	b.WriteString("<style>\n" + htmlStyle + "\n</style>\n")
+	b.WriteString("</head>\n<body>\n<article>\n")
+
+	// Title and metadata
+	b.WriteString("<h1>" + html.EscapeString(article.Title) + "</h1>\n")
+	if article.SiteName != "" {
+		b.WriteString("<p class=\"meta\">" + html.EscapeString(article.SiteName) + "</p>\n")
+	}
+	if article.Description != "" {
+		b.WriteString("<p class=\"meta\"><em>" + html.EscapeString(article.Description) + "</em></p>\n")
+	}
+	b.WriteString("<hr>\n")
+
+	// The blocks of a nested quote share <blockquote> elements; two top-level
+	// quotes in a row stay separate
+	quoteDepth := 0
+	for _, block := range article.Content {
+		depth := 0
+		if block.Type == parser.BlockQuote {
+			depth = max(block.Level, 1)
+		}
+		if depth == 1 && quoteDepth == 1 {
+			b.WriteString("</blockquote>\n<blockquote>\n")
+		}
+		for ; quoteDepth > depth; quoteDepth-- {
+			b.WriteString("</blockquote>\n")
+		}
+		for ; quoteDepth < depth; quoteDepth++ {
+			b.WriteString("<blockquote>\n")
+		}
+
+		switch block.Type {
+		case parser.BlockHeading:
+			level := block.Level
+			if level < 1 || level > 6 {
+				level = 2
+			}
+			b.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", level, text(block.Text), level))
+
+		case parser.BlockParagraph:
+			b.WriteString("<p>" + text(block.Text) + "</p>\n")
+
+		case parser.BlockCode:
+			class := ""
+			if block.Language != "" {
+				class = fmt.Sprintf(" class=\"language-%s\"", html.EscapeString(block.Language))
+			}
+			b.WriteString("<pre><code" + class + ">" + html.EscapeString(block.Text) + "</code></pre>\n")
+
+		case parser.BlockList:
+			tag, attrs := "ul", ""
+			if block.Ordered {
+				tag = "ol"
+				if block.Start != 0 {
+					attrs += fmt.Sprintf(` start="%d"`, block.Start)
+				}
+				switch block.Numbering {
+				case parser.NumberingLowerAlpha:
+					attrs += ` type="a"`
+				case parser.NumberingUpperAlpha:
+					attrs += ` type="A"`
+				case parser.NumberingLowerRoman:
+					attrs += ` type="i"`
+				case parser.NumberingUpperRoman:
+					attrs += ` type="I"`
+				}
+			}
+			b.WriteString("<" + tag + attrs + ">\n")
+			for i, item := range block.Items {
+				box := ""
+				if i < len(block.Tasks) {
+					switch block.Tasks[i] {
+					case parser.TaskChecked:
+						box = `<input type="checkbox" disabled checked> `
+					case parser.TaskUnchecked:
+						box = `<input type="checkbox" disabled> `
+					}
+				}
+				b.WriteString("<li>" + box + text(item) + "</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+
+		case parser.BlockQuote:
+			for _, para := range strings.Split(block.Text, "\n\n") {
+				b.WriteString("<p>" + text(para) + "</p>\n")
+			}
+
+		case parser.BlockImage:
+			if block.URL == "" {
+				continue
+			}
+			b.WriteString(fmt.Sprintf("<img src=\"%s\" alt=\"%s\">\n",
+				html.EscapeString(block.URL), html.EscapeString(block.Alt)))
+
+		case parser.BlockEmbed:
+			b.WriteString(fmt.Sprintf("<p class=\"embed\"><a href=\"%s\">%s</a></p>\n",
+				html.EscapeString(block.URL), html.EscapeString(embedLabel(block))))
+
+		case parser.BlockTable:
+			if len(block.Rows) == 0 {
+				continue
+			}
+			b.WriteString("<table>\n")
+			for i, row := range block.Rows {
+				cellTag := "td"
+				if block.Header && i == 0 {
+					cellTag = "th"
+				}
+				b.WriteString("<tr>")
+				for j, cell := range row {
+					open := "<" + cellTag + ">"
+					if j < len(block.Align) && block.Align[j] != "left" {
+						open = "<" + cellTag + ` style="text-align: ` + block.Align[j] + `">`
+					}
+					b.WriteString(open + text(cell) + "</" + cellTag + ">")
+				}
+				b.WriteString("</tr>\n")
+			}
+			b.WriteString("</table>\n")
+
+		case parser.BlockHR:
+			b.WriteString("<hr>\n")
+
+		case parser.BlockSummary:
+			b.WriteString("<p><strong>" + text(block.Text) + "</strong></p>\n")
+		}
+	}
+	for ; quoteDepth > 0; quoteDepth-- {
+		b.WriteString("</blockquote>\n")
+	}
+
+	// Links
+	if len(article.Links) > 0 {
+		b.WriteString("<hr>\n<h2>Links</h2>\n<ol class=\"links\">\n")
+		for _, link := range article.Links {
+			title := ""
+			if link.Title != "" {
+				title = fmt.Sprintf(" title=\"%s\"", html.EscapeString(link.Title))
+			}
+			b.WriteString(fmt.Sprintf("<li id=\"link-%d\" value=\"%d\"><a href=\"%s\"%s>%s</a></li>\n",
+				link.Index, link.Index, html.EscapeString(link.URL), title, html.EscapeString(link.Text)))
+		}
+		b.WriteString("</ol>\n")
+	}
+
+	b.WriteString("</article>\n</body>\n</html>\n")
+	return b.String()
+}
+
+// linkifyRefs turns [N] link references within escaped text into anchors
+// pointing at the link's URL.
+func linkifyRefs(text string, links map[int]string) string {
+	var result strings.Builder
+	i := 0
+	for i < len(text) {
+		if text[i] == '[' {
+			j := i + 1
+			n := 0
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				n = n*10 + int(text[j]-'0')
+				j++
+			}
+			if j > i+1 && j < len(text) && text[j] == ']' {
+				if url, ok := links[n]; ok {
+					result.WriteString(fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(url), text[i:j+1]))
+					i = j + 1
+					continue
+				}
+			}
+		}
+		result.WriteByte(text[i])
+		i++
+	}
+	return result.String()
+}
+
+// htmlInline translates inline formatting marks into HTML elements.
+func htmlInline(text string) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case parser.MarkBoldOpen:
+			b.WriteString("<strong>")
+		case parser.MarkBoldClose:
+			b.WriteString("</strong>")
+		case parser.MarkItalicOpen:
+			b.WriteString("<em>")
+		case parser.MarkItalicClose:
+			b.WriteString("</em>")
+		case parser.MarkCodeOpen:
+			b.WriteString("<code>")
+		case parser.MarkCodeClose:
+			b.WriteString("</code>")
+		case parser.MarkKbdOpen:
+			b.WriteString("<kbd>")
+		case parser.MarkKbdClose:
+			b.WriteString("</kbd>")
+		case parser.MarkHighlightOpen:
+			b.WriteString("<mark>")
+		case parser.MarkHighlightClose:
+			b.WriteString("</mark>")
+		case parser.MarkAbbrOpen:
+			// The expansion, if any, follows the abbreviation's text
+			title := ""
+			for j := i + 1; j < len(runes) && runes[j] != parser.MarkAbbrClose; j++ {
+				if runes[j] == parser.MarkAbbrTitle {
+					end := j + 1
+					for end < len(runes) && runes[end] != parser.MarkAbbrClose {
+						end++
+					}
+					title = string(runes[j+1 : end])
+					break
+				}
+			}
+			if title != "" {
+				b.WriteString("<abbr title=\"" + title + "\">")
+			} else {
+				b.WriteString("<abbr>")
+			}
+		case parser.MarkAbbrTitle:
+			for i+1 < len(runes) && runes[i+1] != parser.MarkAbbrClose {
+				i++
+			}
+		case parser.MarkAbbrClose:
+			b.WriteString("</abbr>")
+		case parser.MarkStrikeOpen:
+			b.WriteString("<del>")
+		case parser.MarkStrikeClose:
+			b.WriteString("</del>")
+		default:
+			if !parser.IsMark(r) {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
@@ -2,19 +2,28 @@ package renderer
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
-	_ "image/png"
-	"io"
+	"image/png"
+	"mime"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/image/draw"
 	_ "golang.org/x/image/webp"
+	"golang.org/x/term"
 
+	"github.com/0xblz/getwebsite/internal/fetcher"
+	"github.com/0xblz/getwebsite/internal/parser"
 	"github.com/qeesung/image2ascii/convert"
 )
 
@@ -29,53 +38,306 @@ func supportsInlineImages() bool {
 	return lc == "iTerm2"
 }
 
-// fetchImage downloads an image and returns the raw bytes.
-func fetchImage(url string) ([]byte, error) {
+// supportsKitty checks if the terminal supports the Kitty graphics protocol.
+func supportsKitty() bool {
+	if os.Getenv("KITTY_WINDOW_ID") != "" {
+		return true
+	}
+	return strings.Contains(os.Getenv("TERM"), "kitty")
+}
+
+// DetectImages settles now how r will draw images, rather than when it draws
+// the first one. Whether a terminal has sixel graphics may mean asking it, so
+// a program that reads the terminal's input itself, like the reader, calls
+// this before it starts; the answer is remembered for every Renderer.
+func (r *Renderer) DetectImages() {
+	if r.sixelImages {
+		supportsSixel()
+	}
+}
+
+// supportsSixel reports whether the terminal supports the sixel graphics
+// protocol. Terminals not known by TERM or TERM_PROGRAM are asked directly.
+var supportsSixel = sync.OnceValue(func() bool {
+	term := os.Getenv("TERM")
+	if strings.Contains(term, "sixel") {
+		return true
+	}
+	for _, prefix := range []string{"foot", "mlterm", "yaft", "contour"} {
+		if strings.HasPrefix(term, prefix) {
+			return true
+		}
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "contour", "WezTerm":
+		return true
+	}
+	return querySixel()
+})
+
+// sixelQueryTimeout is how long querySixel waits for the terminal to answer.
+const sixelQueryTimeout = 200 * time.Millisecond
+
+// querySixel sends the terminal a Primary Device Attributes (DA1) request and
+// reports whether the reply lists sixel graphics, attribute 4. This catches
+// xterm started with sixel support and other terminals TERM doesn't give
+// away. A terminal that doesn't answer in time counts as no.
+func querySixel() bool {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return false
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	// tty.Fd would switch the file to blocking mode and lose the deadline
+	conn, err := tty.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var state *term.State
+	conn.Control(func(fd uintptr) {
+		state, err = term.MakeRaw(int(fd))
+	})
+	if err != nil {
+		return false
+	}
+	defer conn.Control(func(fd uintptr) {
+		term.Restore(int(fd), state)
+	})
+
+	if err := tty.SetReadDeadline(time.Now().Add(sixelQueryTimeout)); err != nil {
+		return false
+	}
+	if _, err := tty.WriteString("\x1b[c"); err != nil {
+		return false
+	}
+	var reply []byte
+	buf := make([]byte, 64)
+	for {
+		n, err := tty.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if attrs, ok := deviceAttributes(reply); ok {
+			return slices.Contains(attrs, "4")
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// deviceAttributes finds a DA1 reply, ESC [ ? attrs c, in b and returns its
+// semicolon-separated attributes. ok is false until the whole reply is in.
+func deviceAttributes(b []byte) (attrs []string, ok bool) {
+	start := bytes.Index(b, []byte("\x1b[?"))
+	if start < 0 {
+		return nil, false
+	}
+	rest := b[start+3:]
+	end := bytes.IndexByte(rest, 'c')
+	if end < 0 {
+		return nil, false
+	}
+	return strings.Split(string(rest[:end]), ";"), true
+}
+
+// maxImageSize is the largest image that is downloaded.
+const maxImageSize = 5 << 20
+
+// defaultImageFetcher downloads images for callers that don't pass a fetcher.
+var defaultImageFetcher = sync.OnceValue(func() *fetcher.Fetcher {
+	return fetcher.New(fetcher.Options{Timeout: 10 * time.Second})
+})
+
+// fetchImage downloads an image with f, or a default fetcher when f is nil,
+// and returns the raw bytes. Unless noCache is set, fresh copies are served
+// from and saved to the on-disk image cache.
+func fetchImage(ctx context.Context, f *fetcher.Fetcher, url string, noCache bool) ([]byte, error) {
 	if url == "" {
 		return nil, fmt.Errorf("empty url")
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
+	if f == nil {
+		f = defaultImageFetcher()
+	}
+	if !noCache {
+		if data, ok := readCachedImage(url, f.Identity()); ok {
+			return data, nil
+		}
+	}
+
+	result, err := f.FetchImageContext(ctx, url, maxImageSize)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	if !noCache {
+		writeCachedImage(url, f.Identity(), result.ContentType, result.Body)
+	}
+	return result.Body, nil
+}
+
+// imageFetchWorkers bounds how many images are downloaded at once.
+const imageFetchWorkers = 4
+
+// fetchImages downloads the given URLs concurrently and returns the bytes of
+// each one that succeeded, keyed by URL. Once ctx is done the rest are skipped.
+func fetchImages(ctx context.Context, f *fetcher.Fetcher, urls []string, noCache bool) map[string][]byte {
+	images := make(map[string][]byte)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	jobs := make(chan string)
+	for i := 0; i < imageFetchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range jobs {
+				data, err := fetchImage(ctx, f, url, noCache)
+				if err != nil || len(data) == 0 {
+					continue
+				}
+				mu.Lock()
+				images[url] = data
+				mu.Unlock()
+			}
+		}()
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	seen := make(map[string]bool)
+	for _, url := range urls {
+		if !seen[url] {
+			seen[url] = true
+			jobs <- url
+		}
 	}
+	close(jobs)
+	wg.Wait()
 
-	data, err := io.ReadAll(io.LimitReader(resp.Body, 5*1024*1024))
+	return images
+}
+
+// FetchArticleImages downloads every image in article, and its lead image,
+// for rendering later through Renderer.Images, using f (nil means a default
+// fetcher). Downloads stop early once ctx is done.
+func FetchArticleImages(ctx context.Context, f *fetcher.Fetcher, article *parser.Article, noCache bool) map[string][]byte {
+	urls := imageURLs(article.Content)
+	if article.LeadImageURL != "" && !slices.Contains(urls, article.LeadImageURL) {
+		urls = append(urls, article.LeadImageURL)
+	}
+	return fetchImages(ctx, f, urls, noCache)
+}
+
+// ImageURL returns the URL of image number n, counting from 1, of the
+// article's Images section.
+func ImageURL(article *parser.Article, n int) (string, bool) {
+	urls := imageURLs(article.Content)
+	if n < 1 || n > len(urls) {
+		return "", false
+	}
+	return urls[n-1], true
+}
+
+// SaveImage downloads the image at url with f (nil means a default fetcher),
+// or takes it from the image cache unless noCache is set, and writes it to
+// path. A path without an extension gets one for the image's type. It returns
+// the path written.
+func SaveImage(ctx context.Context, f *fetcher.Fetcher, url, path string, noCache bool) (string, error) {
+	data, err := fetchImage(ctx, f, url, noCache)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return data, nil
+	if filepath.Ext(path) == "" {
+		path += imageExtension(imageType(data, url))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
-// renderInlineImage returns the iTerm2 escape sequence to display an image inline.
-func renderInlineImage(url string, maxWidth int) string {
-	data, err := fetchImage(url)
-	if err != nil || len(data) == 0 {
-		return ""
+// imageType works out the content type of image data, falling back to the
+// URL's extension for formats that can't be sniffed, like SVG.
+func imageType(data []byte, url string) string {
+	if t := http.DetectContentType(data); strings.HasPrefix(t, "image/") {
+		return t
+	}
+	if t := mime.TypeByExtension(pathExt(url)); strings.HasPrefix(t, "image/") {
+		return t
+	}
+	return ""
+}
+
+// pathExt returns the extension of the last path segment of a URL, ignoring
+// any query or fragment.
+func pathExt(url string) string {
+	url, _, _ = strings.Cut(url, "#")
+	url, _, _ = strings.Cut(url, "?")
+	return filepath.Ext(url)
+}
+
+// imageURLs returns the URLs of the image blocks in blocks.
+func imageURLs(blocks []parser.ContentBlock) []string {
+	var urls []string
+	for _, block := range blocks {
+		if block.Type == parser.BlockImage && block.URL != "" {
+			urls = append(urls, block.URL)
+		}
 	}
+	return urls
+}
 
+// renderInlineImage returns the iTerm2 escape sequence to display image data inline.
+func renderInlineImage(data []byte, maxWidth int) string {
 	encoded := base64.StdEncoding.EncodeToString(data)
 	width := fmt.Sprintf("%d", maxWidth)
 	return fmt.Sprintf("\033]1337;File=inline=1;width=%s;preserveAspectRatio=1:%s\a",
 		width, encoded)
 }
 
-const maxASCIIWidth = 30
-const maxASCIIHeight = 15
+// Kitty limits each escape sequence payload to 4096 bytes of base64 data.
+const kittyChunkSize = 4096
+
+// renderKittyImage returns the Kitty graphics protocol
+// escape sequences to display it, transcoded to PNG and split into chunks.
+func renderKittyImage(data []byte, maxWidth int) string {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
 
-// renderASCIIImage fetches an image and converts it to ASCII art.
-func renderASCIIImage(url string, maxWidth int) string {
-	data, err := fetchImage(url)
-	if err != nil || len(data) == 0 {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
 		return ""
 	}
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
 
+	var b strings.Builder
+	for i := 0; i < len(encoded); i += kittyChunkSize {
+		end := i + kittyChunkSize
+		more := 1
+		if end >= len(encoded) {
+			end = len(encoded)
+			more = 0
+		}
+		if i == 0 {
+			b.WriteString(fmt.Sprintf("\033_Ga=T,f=100,c=%d,m=%d;", maxWidth, more))
+		} else {
+			b.WriteString(fmt.Sprintf("\033_Gm=%d;", more))
+		}
+		b.WriteString(encoded[i:end])
+		b.WriteString("\033\\")
+	}
+	return b.String()
+}
+
+const maxASCIIWidth = 30
+const maxASCIIHeight = 15
+
+// renderASCIIImage converts image data to ASCII art, colored with truecolor
+// escapes when colored is set.
+func renderASCIIImage(data []byte, maxWidth int, colored bool) string {
 	img, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
 		return ""
@@ -112,7 +374,185 @@ func renderASCIIImage(url string, maxWidth int) string {
 	opts := convert.DefaultOptions
 	opts.FixedWidth = width
 	opts.FixedHeight = height
-	opts.Colored = true
+	opts.Colored = colored
 
 	return converter.Image2ASCIIString(img, &opts)
 }
+
+const maxHalfBlockWidth = 60
+const maxHalfBlockHeight = 30
+
+// renderHalfBlockImage renders image data with "▀" glyphs, using
+// the foreground for the top pixel and the background for the bottom pixel so
+// each character cell shows two rows of the image.
+func renderHalfBlockImage(data []byte, maxWidth int) string {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+
+	bounds := img.Bounds()
+	imgW := bounds.Dx()
+	imgH := bounds.Dy()
+	if imgW == 0 || imgH == 0 {
+		return ""
+	}
+
+	width := maxHalfBlockWidth
+	if maxWidth-2 < width {
+		width = maxWidth - 2
+	}
+	if imgW < width {
+		width = imgW
+	}
+
+	// One cell is one pixel wide and two pixels tall
+	rows := (imgH*width/imgW + 1) / 2
+	if rows > maxHalfBlockHeight {
+		rows = maxHalfBlockHeight
+		width = imgW * rows * 2 / imgH
+	}
+	if width < 1 || rows < 1 {
+		return ""
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, rows*2))
+	draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+
+	var b strings.Builder
+	for row := 0; row < rows; row++ {
+		b.WriteString("  ")
+		for x := 0; x < width; x++ {
+			top := scaled.RGBAAt(x, row*2)
+			bottom := scaled.RGBAAt(x, row*2+1)
+			b.WriteString(fmt.Sprintf("\033[38;2;%d;%d;%dm\033[48;2;%d;%d;%dm▀",
+				top.R, top.G, top.B, bottom.R, bottom.G, bottom.B))
+		}
+		b.WriteString("\033[0m\n")
+	}
+	return b.String()
+}
+
+// Approximate pixel width of a terminal cell, used to size sixel output.
+const sixelCellWidth = 8
+const maxSixelHeight = 480
+
+// renderSixelImage encodes image data as a sixel sequence.
+func renderSixelImage(data []byte, maxWidth int) string {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+
+	bounds := img.Bounds()
+	imgW := bounds.Dx()
+	imgH := bounds.Dy()
+	if imgW == 0 || imgH == 0 {
+		return ""
+	}
+
+	width := imgW
+	if limit := maxWidth * sixelCellWidth; width > limit {
+		width = limit
+	}
+	height := imgH * width / imgW
+	if height > maxSixelHeight {
+		height = maxSixelHeight
+		width = imgW * height / imgH
+	}
+	if width < 1 || height < 1 {
+		return ""
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+
+	return encodeSixel(scaled)
+}
+
+// encodeSixel encodes an image as a sixel sequence using a 6x6x6 color cube palette.
+func encodeSixel(img *image.RGBA) string {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	// Quantize every pixel to a palette index up front
+	pixels := make([]int, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+			r := (int(c.R)*5 + 127) / 255
+			g := (int(c.G)*5 + 127) / 255
+			b := (int(c.B)*5 + 127) / 255
+			pixels[y*width+x] = r*36 + g*6 + b
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("\033Pq")
+	b.WriteString(fmt.Sprintf("\"1;1;%d;%d", width, height))
+
+	// Palette definitions (RGB components are percentages)
+	for i := 0; i < 216; i++ {
+		r := i / 36 * 100 / 5
+		g := i / 6 % 6 * 100 / 5
+		bl := i % 6 * 100 / 5
+		b.WriteString(fmt.Sprintf("#%d;2;%d;%d;%d", i, r, g, bl))
+	}
+
+	// Each sixel band covers six rows of pixels
+	for top := 0; top < height; top += 6 {
+		var used [216]bool
+		for y := top; y < top+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[pixels[y*width+x]] = true
+			}
+		}
+
+		first := true
+		for color := 0; color < 216; color++ {
+			if !used[color] {
+				continue
+			}
+			if !first {
+				b.WriteByte('$')
+			}
+			first = false
+			b.WriteString(fmt.Sprintf("#%d", color))
+
+			run := 0
+			var last byte
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && top+dy < height; dy++ {
+					if pixels[(top+dy)*width+x] == color {
+						bits |= 1 << dy
+					}
+				}
+				ch := 63 + bits
+				if run > 0 && ch != last {
+					writeSixelRun(&b, last, run)
+					run = 0
+				}
+				last = ch
+				run++
+			}
+			writeSixelRun(&b, last, run)
+		}
+		b.WriteByte('-')
+	}
+
+	b.WriteString("\033\\")
+	return b.String()
+}
+
+// writeSixelRun writes a sixel character, run-length encoded when repeated.
+func writeSixelRun(b *strings.Builder, ch byte, count int) {
+	if count > 3 {
+		b.WriteString(fmt.Sprintf("!%d%c", count, ch))
+		return
+	}
+	for i := 0; i < count; i++ {
+		b.WriteByte(ch)
+	}
+}
@@ -2,35 +2,55 @@ package renderer
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/0xblz/getwebsite/internal/parser"
+	"github.com/charmbracelet/x/ansi"
 )
 
+// MarkdownOptions controls RenderMarkdown.
+type MarkdownOptions struct {
+	FrontMatter bool // put the metadata in a YAML front matter block instead of the body
+	Wrap        int  // hard-wrap paragraphs and list items at this many columns; 0 means don't
+	Anchors     bool // end each heading with a {#slug} anchor derived from its text
+}
+
 // RenderMarkdown converts an Article back to markdown.
-func RenderMarkdown(article *parser.Article) string {
+func RenderMarkdown(article *parser.Article, opts MarkdownOptions) string {
 	var b strings.Builder
 
-	// Title
-	b.WriteString("# " + article.Title + "\n\n")
+	if opts.FrontMatter {
+		writeFrontMatter(&b, article)
+	} else {
+		// Title
+		b.WriteString("# " + article.Title + "\n\n")
 
-	// Metadata
-	if article.SiteName != "" {
-		b.WriteString("*" + article.SiteName + "*\n\n")
-	}
-	if article.Description != "" {
-		b.WriteString("> " + article.Description + "\n\n")
-	}
+		// Metadata
+		if article.SiteName != "" {
+			b.WriteString("*" + article.SiteName + "*\n\n")
+		}
+		if article.Description != "" {
+			b.WriteString("> " + article.Description + "\n\n")
+		}
 
-	b.WriteString("---\n\n")
+		b.WriteString("---\n\n")
+	}
 
-	for _, block := range article.Content {
+	slugs := make(map[string]int)
+	for i, block := range article.Content {
 		switch block.Type {
 		case parser.BlockHeading:
-			b.WriteString(strings.Repeat("#", block.Level) + " " + block.Text + "\n\n")
+			heading := strings.Repeat("#", block.Level) + " " + block.Text
+			if opts.Anchors {
+				heading += " {#" + headingSlug(block.Text, slugs) + "}"
+			}
+			b.WriteString(heading + "\n\n")
 
 		case parser.BlockParagraph:
-			b.WriteString(block.Text + "\n\n")
+			b.WriteString(markdownBreaks(markdownInline(block.Text), "", opts.Wrap) + "\n\n")
 
 		case parser.BlockCode:
 			lang := block.Language
@@ -40,20 +60,52 @@ func RenderMarkdown(article *parser.Article) string {
 
 		case parser.BlockList:
 			for i, item := range block.Items {
+				item = markdownInline(item)
+				prefix := "- "
 				if block.Ordered {
-					b.WriteString(fmt.Sprintf("%d. %s\n", i+1, item))
-				} else {
-					b.WriteString("- " + item + "\n")
+					// Markdown lists only number in decimal, but do keep the
+					// start: renderers count on from the first number
+					n := i + 1
+					if block.Start > 0 {
+						n = block.Start + i
+					}
+					prefix = fmt.Sprintf("%d. ", n)
 				}
+				indent := strings.Repeat(" ", len(prefix))
+				if i < len(block.Tasks) {
+					switch block.Tasks[i] {
+					case parser.TaskChecked:
+						prefix += "[x] "
+					case parser.TaskUnchecked:
+						prefix += "[ ] "
+					}
+				}
+				b.WriteString(prefix + markdownBreaks(item, indent, opts.Wrap) + "\n")
 			}
 			b.WriteString("\n")
 
 		case parser.BlockQuote:
-			lines := strings.Split(block.Text, "\n")
-			for _, line := range lines {
-				b.WriteString("> " + line + "\n")
+			depth := max(block.Level, 1)
+			prefix := strings.Repeat("> ", depth)
+			lines := strings.Split(markdownInline(block.Text), "\n")
+			for j, line := range lines {
+				if line == "" {
+					// A paragraph break inside the quote
+					b.WriteString(strings.TrimSpace(prefix) + "\n")
+					continue
+				}
+				if j < len(lines)-1 && lines[j+1] != "" {
+					line += "  "
+				}
+				b.WriteString(prefix + line + "\n")
+			}
+			// Keep the levels of a nested quote inside one blockquote by
+			// separating them with a quoted blank line
+			if shared := nestedQuoteGap(article.Content, i); shared > 0 {
+				b.WriteString(strings.TrimSpace(strings.Repeat("> ", shared)) + "\n")
+			} else {
+				b.WriteString("\n")
 			}
-			b.WriteString("\n")
 
 		case parser.BlockImage:
 			alt := block.Alt
@@ -86,7 +138,20 @@ func RenderMarkdown(article *parser.Article) string {
 				// Separator line (required for valid markdown tables)
 				b.WriteString("|")
 				for j := 0; j < numCols; j++ {
-					b.WriteString(" --- |")
+					align := ""
+					if j < len(block.Align) {
+						align = block.Align[j]
+					}
+					switch align {
+					case "center":
+						b.WriteString(" :-: |")
+					case "right":
+						b.WriteString(" --: |")
+					case "left":
+						b.WriteString(" :-- |")
+					default:
+						b.WriteString(" --- |")
+					}
 				}
 				b.WriteString("\n")
 			}
@@ -104,8 +169,14 @@ func RenderMarkdown(article *parser.Article) string {
 			}
 			b.WriteString("\n")
 
+		case parser.BlockEmbed:
+			b.WriteString(fmt.Sprintf("[%s](%s)\n\n", embedLabel(block), block.URL))
+
 		case parser.BlockHR:
 			b.WriteString("---\n\n")
+
+		case parser.BlockSummary:
+			b.WriteString("**" + block.Text + "**\n\n")
 		}
 	}
 
@@ -114,9 +185,250 @@ func RenderMarkdown(article *parser.Article) string {
 		b.WriteString("---\n\n")
 		b.WriteString("## Links\n\n")
 		for _, link := range article.Links {
-			b.WriteString(fmt.Sprintf("[%d]: %s (%s)\n", link.Index, link.URL, link.Text))
+			title := link.Title
+			if title == "" {
+				title = link.Text
+			}
+			b.WriteString(fmt.Sprintf("[%d]: %s (%s)\n", link.Index, link.URL, title))
 		}
 	}
 
 	return b.String()
 }
+
+// headingSlug returns a GitHub-style anchor for a heading: lowercase, with
+// spaces turned into hyphens and other punctuation dropped. seen counts the
+// slugs handed out so far, so repeats get -1, -2, ... suffixes.
+func headingSlug(text string, seen map[string]int) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(parser.StripInline(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	slug := b.String()
+	if slug == "" {
+		slug = "section"
+	}
+	n, ok := seen[slug]
+	seen[slug] = n + 1
+	if !ok {
+		return slug
+	}
+	// A suffixed slug can collide with a heading that reads that way itself
+	for {
+		candidate := slug + "-" + strconv.Itoa(n)
+		if _, taken := seen[candidate]; !taken {
+			seen[candidate] = 1
+			return candidate
+		}
+		n++
+		seen[slug] = n + 1
+	}
+}
+
+// writeFrontMatter writes the article's metadata as a YAML front matter block,
+// as read by static site generators. Empty fields are left out.
+func writeFrontMatter(b *strings.Builder, article *parser.Article) {
+	b.WriteString("---\n")
+	field := func(key, value string) {
+		if value != "" {
+			b.WriteString(key + ": " + yamlString(value) + "\n")
+		}
+	}
+	field("title", article.Title)
+	field("author", article.Author)
+	field("site", article.SiteName)
+	field("description", article.Description)
+	if !article.PublishDate.IsZero() {
+		field("date", article.PublishDate.Format(time.RFC3339))
+	}
+	field("source_url", article.URL)
+	b.WriteString("---\n\n")
+}
+
+// yamlString returns s as a YAML scalar, double-quoting it when it contains
+// a colon or anything else YAML would read as syntax.
+func yamlString(s string) string {
+	if s != strings.TrimSpace(s) || strings.ContainsAny(s, ":#\"'\\\n\t") ||
+		strings.ContainsAny(s[:1], "-?[]{},&*!|>%@`") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// markdownInline translates inline formatting marks into markdown emphasis
+// and code spans.
+func markdownInline(text string) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case parser.MarkBoldOpen, parser.MarkBoldClose:
+			b.WriteString("**")
+		case parser.MarkItalicOpen, parser.MarkItalicClose:
+			b.WriteString("*")
+		case parser.MarkHighlightOpen, parser.MarkHighlightClose:
+			b.WriteString("==")
+		case parser.MarkStrikeOpen, parser.MarkStrikeClose:
+			b.WriteString("~~")
+		case parser.MarkAbbrTitle:
+			// Spell out an abbreviation after its first use
+			j := i + 1
+			for j < len(runes) && runes[j] != parser.MarkAbbrClose {
+				j++
+			}
+			b.WriteString(" (" + string(runes[i+1:j]) + ")")
+			i = j
+		case parser.MarkCodeOpen, parser.MarkKbdOpen:
+			// Code spans are emitted whole so their fence can fit the content;
+			// markdown has no key caps, so keys become code spans too
+			closing := parser.MarkCodeClose
+			if r == parser.MarkKbdOpen {
+				closing = parser.MarkKbdClose
+			}
+			j := i + 1
+			for j < len(runes) && runes[j] != closing {
+				j++
+			}
+			b.WriteString(markdownCodeSpan(parser.StripInline(string(runes[i+1 : j]))))
+			i = j
+		default:
+			if !parser.IsMark(r) {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// markdownBreaks turns line breaks into markdown hard breaks (two trailing
+// spaces), indenting continuation lines so they stay inside list items. With
+// a wrap width, each line is also wrapped to fit it, indent included.
+func markdownBreaks(text, indent string, wrap int) string {
+	if wrap <= 0 {
+		return strings.ReplaceAll(text, "\n", "  \n"+indent)
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(markdownWrap(line, wrap-len(indent)), "\n"+indent)
+	}
+	return strings.Join(lines, "  \n"+indent)
+}
+
+// markdownWrap breaks text into lines of at most width columns at spaces.
+// Code spans are never split, a [N] reference stays with the word before it,
+// and nothing that would read as markdown syntax (a list marker, "#" or ">")
+// is left at the start of a line. Words longer than width get a line of
+// their own.
+func markdownWrap(text string, width int) []string {
+	var words []string
+	for _, word := range markdownWords(text) {
+		if len(words) > 0 && (isLinkRef(strings.TrimRight(word, ".,;:!?)")) || startsBlock(word)) {
+			words[len(words)-1] += " " + word
+			continue
+		}
+		words = append(words, word)
+	}
+
+	var lines []string
+	line := ""
+	for _, word := range words {
+		switch {
+		case line == "":
+			line = word
+		case ansi.StringWidth(line)+1+ansi.StringWidth(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	return append(lines, line)
+}
+
+// markdownWords splits text at spaces, keeping each code span in one piece.
+func markdownWords(text string) []string {
+	var words []string
+	var word strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case ' ':
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		case '`':
+			// Copy the whole span, up to a closing run of as many backticks
+			end := codeSpanEnd(text, i)
+			word.WriteString(text[i:end])
+			i = end - 1
+		default:
+			word.WriteByte(text[i])
+		}
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// codeSpanEnd returns the index just past the code span whose opening
+// backticks start at text[i], or past the backticks alone if nothing closes it.
+func codeSpanEnd(text string, i int) int {
+	run := func(j int) int {
+		n := 0
+		for j+n < len(text) && text[j+n] == '`' {
+			n++
+		}
+		return n
+	}
+	open := run(i)
+	for j := i + open; j < len(text); j++ {
+		if text[j] == '`' {
+			n := run(j)
+			if n == open {
+				return j + n
+			}
+			j += n - 1
+		}
+	}
+	return i + open
+}
+
+// startsBlock reports whether word at the start of a line would begin a
+// markdown list item, heading or quote.
+func startsBlock(word string) bool {
+	switch {
+	case word == "-" || word == "+" || word == "*" || word == ">" || strings.HasPrefix(word, "#"):
+		return true
+	case strings.HasSuffix(word, ".") || strings.HasSuffix(word, ")"):
+		_, err := strconv.Atoi(word[:len(word)-1])
+		return err == nil
+	}
+	return false
+}
+
+// markdownCodeSpan wraps code in backticks, using a longer fence (padded with
+// spaces) when the code itself contains backticks.
+func markdownCodeSpan(code string) string {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest == 0 {
+		return "`" + code + "`"
+	}
+	fence := strings.Repeat("`", longest+1)
+	return fence + " " + code + " " + fence
+}
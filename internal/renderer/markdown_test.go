@@ -0,0 +1,35 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+)
+
+func TestRenderMarkdownColspanHeader(t *testing.T) {
+	article, err := parser.Parse([]byte(`<html><body><article>
+<table>
+<thead><tr><th>Name</th><th colspan="2">Size</th></tr></thead>
+<tbody><tr><td>a</td><td>1</td><td>2</td></tr></tbody>
+</table>
+</article></body></html>`), "https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var table []string
+	for _, line := range strings.Split(RenderMarkdown(article, MarkdownOptions{}), "\n") {
+		if strings.HasPrefix(line, "|") {
+			table = append(table, line)
+		}
+	}
+	want := []string{
+		"| Name | Size | Size |",
+		"| --- | --- | --- |",
+		"| a | 1 | 2 |",
+	}
+	if strings.Join(table, "\n") != strings.Join(want, "\n") {
+		t.Errorf("table:\n%s\nwant:\n%s", strings.Join(table, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,124 @@
+package renderer
+
+import (
+	"strings"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+)
+
+// RenderPlainText converts an Article to plain prose with no escape sequences
+// and no markup, for text-to-speech and other tools that want only the words.
+// Link references are replaced by the URL in parentheses.
+func RenderPlainText(article *parser.Article) string {
+	var b strings.Builder
+
+	links := make(map[int]string)
+	for _, link := range article.Links {
+		links[link.Index] = link.URL
+	}
+	inline := func(s string) string {
+		return inlineLinkURLs(parser.StripInline(s), links)
+	}
+
+	b.WriteString(article.Title + "\n\n")
+	if article.SiteName != "" {
+		b.WriteString(article.SiteName + "\n\n")
+	}
+	if article.Description != "" {
+		b.WriteString(article.Description + "\n\n")
+	}
+
+	for _, block := range article.Content {
+		switch block.Type {
+		case parser.BlockHeading:
+			b.WriteString(block.Text + "\n\n")
+
+		case parser.BlockParagraph, parser.BlockSummary:
+			b.WriteString(inline(block.Text) + "\n\n")
+
+		case parser.BlockCode:
+			for _, line := range strings.Split(block.Text, "\n") {
+				b.WriteString(strings.TrimRight("    "+line, " ") + "\n")
+			}
+			b.WriteString("\n")
+
+		case parser.BlockList:
+			for i, item := range block.Items {
+				prefix := "- "
+				if block.Ordered {
+					prefix = listNumber(block, i) + ". "
+				}
+				indent := strings.Repeat(" ", len(prefix))
+				if i < len(block.Tasks) {
+					switch block.Tasks[i] {
+					case parser.TaskChecked:
+						prefix += "[x] "
+					case parser.TaskUnchecked:
+						prefix += "[ ] "
+					}
+				}
+				b.WriteString(prefix + strings.ReplaceAll(inline(item), "\n", "\n"+indent) + "\n")
+			}
+			b.WriteString("\n")
+
+		case parser.BlockQuote:
+			indent := strings.Repeat("  ", max(block.Level, 1))
+			for _, line := range strings.Split(inline(block.Text), "\n") {
+				if line == "" {
+					b.WriteString("\n")
+					continue
+				}
+				b.WriteString(indent + line + "\n")
+			}
+			b.WriteString("\n")
+
+		case parser.BlockImage:
+			if block.Alt != "" {
+				b.WriteString("Image: " + block.Alt + "\n\n")
+			}
+
+		case parser.BlockEmbed:
+			b.WriteString(embedLabel(block) + " (" + block.URL + ")\n\n")
+
+		case parser.BlockTable:
+			cellSpace := strings.NewReplacer("\t", " ", "\n", " ")
+			for _, row := range block.Rows {
+				cells := make([]string, len(row))
+				for j, cell := range row {
+					cells[j] = cellSpace.Replace(cell)
+				}
+				b.WriteString(strings.Join(cells, "\t") + "\n")
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// inlineLinkURLs replaces [N] link references in text with the link's URL in
+// parentheses.
+func inlineLinkURLs(text string, links map[int]string) string {
+	var result strings.Builder
+	i := 0
+	for i < len(text) {
+		if text[i] == '[' {
+			j := i + 1
+			n := 0
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				n = n*10 + int(text[j]-'0')
+				j++
+			}
+			if j > i+1 && j < len(text) && text[j] == ']' {
+				if url, ok := links[n]; ok {
+					result.WriteString("(" + url + ")")
+					i = j + 1
+					continue
+				}
+			}
+		}
+		result.WriteByte(text[i])
+		i++
+	}
+	return result.String()
+}
@@ -1,52 +1,104 @@
 package renderer
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/0xblz/getwebsite/internal/fetcher"
 	"github.com/0xblz/getwebsite/internal/parser"
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/formatters"
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
 )
 
-var (
-	ColorHeading   = lipgloss.Color("205")
-	ColorH2        = lipgloss.Color("212")
-	ColorH3        = lipgloss.Color("218")
-	ColorLink      = lipgloss.Color("86")
-	ColorLinkRef   = lipgloss.Color("243")
-	ColorCode      = lipgloss.Color("228")
-	ColorCodeBG    = lipgloss.Color("236")
-	ColorQuote     = lipgloss.Color("243")
-	ColorQuoteLine = lipgloss.Color("205")
-	ColorMeta      = lipgloss.Color("243")
-	ColorBullet    = lipgloss.Color("205")
-	ColorImage     = lipgloss.Color("243")
-	ColorHR        = lipgloss.Color("240")
-)
+// Options controls optional renderer behavior.
+type Options struct {
+	ASCIIImages     bool   // use ASCII art instead of half-block images as the fallback
+	NoImages        bool   // never fetch images; skip the Images section entirely
+	NoCache         bool   // bypass the on-disk image cache
+	Theme           Theme  // colors; the zero value means DarkTheme
+	CodeStyle       string // chroma style for code blocks; empty means DefaultCodeStyle
+	LineNumbers     bool   // number the lines of code blocks
+	ExpandAbbr      bool   // follow an abbreviation's first use with its expansion
+	WPM             int    // reading speed for the time estimate; zero means DefaultWPM
+	TableTruncate   bool   // cut long table cells off with "…" instead of wrapping them
+	Favicon         bool   // draw the site's icon before the title on terminals that show images inline
+	NoHero          bool   // don't show the og:image lead image under the title
+	SuperscriptRefs bool   // show link references as superscript digits, word³, instead of "word [3]"
+	Compact         bool   // dense layout: no title box, code borders or heading dividers, lighter tables
+	Glamour         bool   // render the article's markdown with glamour instead of the built-in renderer
+
+	// Fetcher downloads images, so they go through the same proxy, headers,
+	// cookies and rate limit as the page; nil means a default one.
+	Fetcher *fetcher.Fetcher
+}
+
+// DefaultCodeStyle is the chroma style used when Options.CodeStyle is empty.
+const DefaultCodeStyle = "monokai"
 
 type Renderer struct {
 	width        int
+	opts         Options
+	theme        Theme
 	inlineImages bool
-	HeadingLines []int // line indices of headings in rendered output
+	kittyImages  bool
+	sixelImages  bool        // sixel may be used, if supportsSixel says the terminal has it
+	HeadingLines []int       // line indices of headings in rendered output
+	LinkLines    map[int]int // link index for each line of the Links section
+
+	// Images holds already-downloaded image data keyed by URL. When nil, the
+	// article's images are downloaded while rendering; otherwise images missing
+	// from it are shown as placeholders.
+	Images map[string][]byte
+
+	blockRenderers map[parser.BlockType]BlockRenderer // overrides set with SetBlockRenderer
 }
 
-func New(width int) *Renderer {
-	return &Renderer{
+// New returns a Renderer. A zero opts.Theme means DarkTheme.
+func New(width int, opts Options) *Renderer {
+	theme := opts.Theme
+	if theme.Name == "" {
+		theme = DarkTheme
+	}
+	r := &Renderer{
 		width:        width,
+		opts:         opts,
+		theme:        theme,
 		inlineImages: supportsInlineImages(),
+		kittyImages:  supportsKitty(),
 	}
+	// Sixel is the last choice. Whether the terminal has it may mean asking
+	// it, so that waits until an image is drawn (see renderImageData)
+	r.sixelImages = !opts.NoImages && !r.inlineImages && !r.kittyImages
+	return r
+}
+
+// NewWithTheme returns a Renderer that uses theme's colors.
+func NewWithTheme(width int, theme Theme) *Renderer {
+	return New(width, Options{Theme: theme})
 }
 
 func (r *Renderer) RenderArticle(article *parser.Article) string {
+	if r.opts.Glamour {
+		if out, err := r.renderGlamour(article); err == nil {
+			return out
+		}
+	}
+
 	var b strings.Builder
 
 	// Title header
-	b.WriteString(r.renderTitle(article))
+	b.WriteString(r.renderTitle(article, true))
 	b.WriteString("\n\n")
+	if hero := r.renderHero(article); hero != "" {
+		b.WriteString(hero + "\n")
+	}
 
 	// Content blocks (skip images — they're rendered at the bottom)
 	r.HeadingLines = nil
@@ -56,8 +108,8 @@ func (r *Renderer) RenderArticle(article *parser.Article) string {
 		}
 
 		// Add a subtle divider before headings (except the first block)
-		if block.Type == parser.BlockHeading && i > 0 {
-			dividerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("238"))
+		if block.Type == parser.BlockHeading && i > 0 && !r.opts.Compact {
+			dividerStyle := lipgloss.NewStyle().Foreground(r.theme.Divider)
 			b.WriteString(dividerStyle.Render("  "+strings.Repeat("─", r.width-4)) + "\n")
 		}
 
@@ -70,58 +122,135 @@ func (r *Renderer) RenderArticle(article *parser.Article) string {
 		rendered := r.RenderBlock(block)
 		if rendered != "" {
 			b.WriteString(rendered)
-			b.WriteString("\n")
+			if r.opts.Compact && block.Type == parser.BlockHeading {
+				// A heading sits right on top of its section
+				continue
+			}
+			if shared := nestedQuoteGap(article.Content, i); shared > 0 {
+				// Carry the outer bars across the gap inside a nested quote
+				b.WriteString("  " + r.quoteBars(shared) + "\n")
+			} else {
+				b.WriteString("\n")
+			}
 		}
 	}
 
 	// Images section
-	var imageSection strings.Builder
+	if !r.opts.NoImages {
+		b.WriteString(r.renderImageSection(article.Content))
+	}
+
+	// Link footnotes
+	r.LinkLines = nil
+	if len(article.Links) > 0 {
+		b.WriteString(r.renderLinks(article.Links, strings.Count(b.String(), "\n")))
+	}
+
+	return b.String()
+}
+
+// renderHero renders the article's lead image under the title box, unless
+// it is turned off or the body shows the same image anyway.
+func (r *Renderer) renderHero(article *parser.Article) string {
+	if r.opts.NoHero || r.opts.NoImages || article.LeadImageURL == "" {
+		return ""
+	}
 	for _, block := range article.Content {
+		if block.Type == parser.BlockImage && block.URL == article.LeadImageURL {
+			return ""
+		}
+	}
+	var data []byte
+	if r.Images != nil {
+		data = r.Images[article.LeadImageURL]
+	} else {
+		data, _ = fetchImage(context.Background(), r.opts.Fetcher, article.LeadImageURL, r.opts.NoCache)
+	}
+	return r.renderImageData(parser.ContentBlock{
+		Type: parser.BlockImage,
+		URL:  article.LeadImageURL,
+		Alt:  article.LeadImageAlt,
+	}, data)
+}
+
+// renderImageSection renders all images in the article under an "Images" header.
+func (r *Renderer) renderImageSection(blocks []parser.ContentBlock) string {
+	images := r.Images
+	if images == nil {
+		// Download everything up front so slow images don't stall one another
+		images = fetchImages(context.Background(), r.opts.Fetcher, imageURLs(blocks), r.opts.NoCache)
+	}
+
+	// Images are numbered so the reader can pick one to save (see ImageURL)
+	var imageSection strings.Builder
+	n := 0
+	for _, block := range blocks {
 		if block.Type == parser.BlockImage && block.URL != "" {
-			rendered := r.renderImage(block)
+			n++
+			rendered := r.renderNumberedImage(block, images[block.URL], n)
 			if rendered != "" {
 				imageSection.WriteString(rendered)
 			}
 		}
 	}
-	if imageSection.Len() > 0 {
-		dividerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("238"))
-		b.WriteString("\n" + dividerStyle.Render("  "+strings.Repeat("─", r.width-4)) + "\n")
-		headerStyle := lipgloss.NewStyle().Bold(true).Foreground(ColorMeta)
-		b.WriteString(headerStyle.Render("  Images") + "\n\n")
-		b.WriteString(imageSection.String())
-	}
-
-	// Link footnotes
-	if len(article.Links) > 0 {
-		b.WriteString(r.renderLinks(article.Links))
+	if imageSection.Len() == 0 {
+		return ""
 	}
 
+	var b strings.Builder
+	dividerStyle := lipgloss.NewStyle().Foreground(r.theme.Divider)
+	b.WriteString("\n" + dividerStyle.Render("  "+strings.Repeat("─", r.width-4)) + "\n")
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(r.theme.Meta)
+	b.WriteString(headerStyle.Render("  Images") + "\n\n")
+	b.WriteString(imageSection.String())
 	return b.String()
 }
 
-func (r *Renderer) renderTitle(article *parser.Article) string {
+// RenderPreview renders just the title box of an article whose body is still
+// being parsed, such as a parser.Preview, without the word count and reading
+// time.
+func (r *Renderer) RenderPreview(article *parser.Article) string {
+	return r.renderTitle(article, false)
+}
+
+// renderTitle renders the title box: title, site name, the reading time when
+// withStats is set, and description.
+func (r *Renderer) renderTitle(article *parser.Article, withStats bool) string {
 	contentWidth := r.width - 4
 
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(ColorHeading).
+		Foreground(r.theme.Heading).
 		Width(contentWidth)
 
+	// The icon can't be measured, so lay the title out around a placeholder
+	// of the same width and swap the icon in afterwards
+	icon := r.favicon(article)
 	title := titleStyle.Render(article.Title)
+	if icon != "" {
+		title = titleStyle.Render(faviconPlaceholder + " " + article.Title)
+	}
 
 	var meta string
 	if article.SiteName != "" {
 		metaStyle := lipgloss.NewStyle().
-			Foreground(ColorMeta).
+			Foreground(r.theme.Meta).
 			Width(contentWidth)
 		meta = metaStyle.Render(article.SiteName)
 	}
 
+	var reading string
+	if withStats {
+		readingStyle := lipgloss.NewStyle().
+			Foreground(r.theme.Meta).
+			Width(contentWidth)
+		reading = readingStyle.Render(ArticleStats(article).ReadingTime(r.opts.WPM).String())
+	}
+
 	var desc string
 	if article.Description != "" {
 		descStyle := lipgloss.NewStyle().
-			Foreground(ColorMeta).
+			Foreground(r.theme.Meta).
 			Italic(true).
 			Width(contentWidth)
 		desc = descStyle.Render(article.Description)
@@ -131,57 +260,126 @@ func (r *Renderer) renderTitle(article *parser.Article) string {
 	if meta != "" {
 		content += "\n" + meta
 	}
+	if reading != "" {
+		content += "\n" + reading
+	}
 	if desc != "" {
 		content += "\n" + desc
 	}
 
 	boxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("240")).
+		BorderForeground(r.theme.Border).
 		Padding(1, 2).
 		Width(r.width)
+	if r.opts.Compact {
+		boxStyle = lipgloss.NewStyle().PaddingLeft(1)
+	}
 
-	return boxStyle.Render(content)
+	return strings.Replace(boxStyle.Render(content), faviconPlaceholder, icon, 1)
 }
 
+// faviconPlaceholder stands in for the favicon, two cells wide, while the
+// title box is laid out.
+const faviconPlaceholder = "\u2800\u2800"
+
+// favicon returns the escape sequence that draws article's icon two cells
+// wide, or "" when Options.Favicon is off, the terminal can't show images
+// inline or the icon can't be loaded.
+func (r *Renderer) favicon(article *parser.Article) string {
+	if !r.opts.Favicon || r.opts.NoImages || article.FaviconURL == "" || !(r.inlineImages || r.kittyImages) {
+		return ""
+	}
+	var data []byte
+	if r.Images != nil {
+		data = r.Images[article.FaviconURL]
+	} else {
+		data, _ = fetchImage(context.Background(), r.opts.Fetcher, article.FaviconURL, r.opts.NoCache)
+	}
+	if len(data) == 0 {
+		return ""
+	}
+	if r.inlineImages {
+		return renderInlineImage(data, 2)
+	}
+	return renderKittyImage(data, 2)
+}
+
+// A BlockRenderer renders one content block for r. The result is joined to
+// the rest of the article as is, so it should end in a newline.
+type BlockRenderer func(block parser.ContentBlock, r *Renderer) string
+
+// defaultBlockRenderers are the built-in renderers for each block type.
+var defaultBlockRenderers = map[parser.BlockType]BlockRenderer{
+	parser.BlockHeading:   func(b parser.ContentBlock, r *Renderer) string { return r.renderHeading(b) },
+	parser.BlockParagraph: func(b parser.ContentBlock, r *Renderer) string { return r.renderParagraph(b) },
+	parser.BlockCode:      func(b parser.ContentBlock, r *Renderer) string { return r.renderCode(b) },
+	parser.BlockList:      func(b parser.ContentBlock, r *Renderer) string { return r.renderList(b) },
+	parser.BlockQuote:     func(b parser.ContentBlock, r *Renderer) string { return r.renderQuote(b) },
+	parser.BlockImage:     func(b parser.ContentBlock, r *Renderer) string { return r.renderImage(b) },
+	parser.BlockTable:     func(b parser.ContentBlock, r *Renderer) string { return r.renderTable(b) },
+	parser.BlockHR:        func(_ parser.ContentBlock, r *Renderer) string { return r.renderHR() },
+	parser.BlockSummary:   func(b parser.ContentBlock, r *Renderer) string { return r.renderSummary(b) },
+	parser.BlockEmbed:     func(b parser.ContentBlock, r *Renderer) string { return r.renderEmbed(b) },
+}
+
+// SetBlockRenderer makes r render blocks of type t with fn instead of the
+// built-in renderer. A nil fn restores the built-in one.
+func (r *Renderer) SetBlockRenderer(t parser.BlockType, fn BlockRenderer) {
+	if fn == nil {
+		delete(r.blockRenderers, t)
+		return
+	}
+	if r.blockRenderers == nil {
+		r.blockRenderers = make(map[parser.BlockType]BlockRenderer)
+	}
+	r.blockRenderers[t] = fn
+}
+
+// DefaultBlockRenderer returns the built-in renderer for t, so an override
+// can fall back to it or decorate its output. It is nil for unknown types.
+func DefaultBlockRenderer(t parser.BlockType) BlockRenderer {
+	return defaultBlockRenderers[t]
+}
+
+// RenderBlock renders one content block, using a renderer set with
+// SetBlockRenderer if there is one. Unknown block types render as "".
 func (r *Renderer) RenderBlock(block parser.ContentBlock) string {
-	switch block.Type {
-	case parser.BlockHeading:
-		return r.renderHeading(block)
-	case parser.BlockParagraph:
-		return r.renderParagraph(block)
-	case parser.BlockCode:
-		return r.renderCode(block)
-	case parser.BlockList:
-		return r.renderList(block)
-	case parser.BlockQuote:
-		return r.renderQuote(block)
-	case parser.BlockImage:
-		return r.renderImage(block)
-	case parser.BlockTable:
-		return r.renderTable(block)
-	case parser.BlockHR:
-		return r.renderHR()
-	default:
+	fn, ok := r.blockRenderers[block.Type]
+	if !ok {
+		fn = defaultBlockRenderers[block.Type]
+	}
+	if fn == nil {
 		return ""
 	}
+	return fn(block, r)
+}
+
+// Width returns the width r lays content out at.
+func (r *Renderer) Width() int {
+	return r.width
+}
+
+// Theme returns the colors r renders with.
+func (r *Renderer) Theme() Theme {
+	return r.theme
 }
 
 func (r *Renderer) renderHeading(block parser.ContentBlock) string {
-	color := ColorHeading
+	color := r.theme.Heading
 	prefix := "▸ "
 	switch block.Level {
 	case 1:
-		color = ColorHeading
+		color = r.theme.Heading
 		prefix = "▸ "
 	case 2:
-		color = ColorH2
+		color = r.theme.H2
 		prefix = "▸ "
 	case 3:
-		color = ColorH3
+		color = r.theme.H3
 		prefix = "  ▹ "
 	default:
-		color = ColorH3
+		color = r.theme.H3
 		prefix = "    ▹ "
 	}
 
@@ -189,12 +387,23 @@ func (r *Renderer) renderHeading(block parser.ContentBlock) string {
 		Bold(true).
 		Foreground(color)
 
+	if r.opts.Compact {
+		return style.Render(prefix+block.Text) + "\n"
+	}
 	return "\n" + style.Render(prefix+block.Text) + "\n"
 }
 
+// renderSummary renders the label of a <details> section; its content follows
+// as ordinary blocks.
+func (r *Renderer) renderSummary(block parser.ContentBlock) string {
+	markerStyle := lipgloss.NewStyle().Foreground(r.theme.Bullet)
+	labelStyle := lipgloss.NewStyle().Bold(true)
+	return " " + markerStyle.Render("▸") + " " + labelStyle.Render(block.Text) + "\n"
+}
+
 func (r *Renderer) renderParagraph(block parser.ContentBlock) string {
-	// Colorize link references [N] within the text
-	text := colorizeLinks(block.Text)
+	// Apply emphasis and colorize link references [N] within the text
+	text := r.renderInline(block.Text, lipgloss.NewStyle())
 
 	style := lipgloss.NewStyle().
 		Width(r.width - 2).
@@ -204,54 +413,199 @@ func (r *Renderer) renderParagraph(block parser.ContentBlock) string {
 }
 
 func (r *Renderer) renderCode(block parser.ContentBlock) string {
-	highlighted := highlightCode(block.Text, block.Language)
+	highlighted := r.highlightCode(block.Text, block.Language)
 
 	boxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("240")).
+		BorderForeground(r.theme.Border).
 		Padding(0, 1).
 		MarginLeft(2).
 		Width(r.width - 6)
 
+	if r.opts.Compact {
+		// Just the code, indented under the text
+		boxStyle = lipgloss.NewStyle().
+			MarginLeft(2).
+			Width(r.width - 4)
+	}
+
+	if r.opts.LineNumbers {
+		// Width includes the padding but not the border
+		highlighted = r.numberCodeLines(highlighted, boxStyle.GetWidth()-boxStyle.GetHorizontalPadding())
+	}
+
 	return boxStyle.Render(highlighted) + "\n"
 }
 
+// numberCodeLines prefixes each line of highlighted code with a right-aligned
+// line number gutter. Lines are wrapped to fit width here rather than by the
+// box, so continuation lines get an empty gutter instead of a number.
+func (r *Renderer) numberCodeLines(highlighted string, width int) string {
+	lines := strings.Split(highlighted, "\n")
+	digits := len(strconv.Itoa(len(lines)))
+	gutterStyle := lipgloss.NewStyle().Foreground(r.theme.Border)
+	blank := gutterStyle.Render(strings.Repeat(" ", digits) + " │ ")
+
+	codeWidth := width - digits - 3
+	if codeWidth < 1 {
+		return highlighted
+	}
+
+	var out []string
+	for i, line := range lines {
+		// Expand tabs the way lipgloss does so the wrap width is accurate
+		line = strings.ReplaceAll(line, "\t", "    ")
+		gutter := gutterStyle.Render(fmt.Sprintf("%*d │ ", digits, i+1))
+		for j, part := range strings.Split(ansi.Hardwrap(line, codeWidth, true), "\n") {
+			if j == 0 {
+				out = append(out, gutter+part)
+			} else {
+				out = append(out, blank+part)
+			}
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
 func (r *Renderer) renderList(block parser.ContentBlock) string {
 	var b strings.Builder
-	bulletStyle := lipgloss.NewStyle().Foreground(ColorBullet)
+	bulletStyle := lipgloss.NewStyle().Foreground(r.theme.Bullet)
+	checkedStyle := lipgloss.NewStyle().Foreground(r.theme.Link).Bold(true)
+	uncheckedStyle := lipgloss.NewStyle().Foreground(r.theme.Meta)
 
 	for i, item := range block.Items {
-		item = colorizeLinks(item)
+		item = r.renderInline(item, lipgloss.NewStyle())
+
+		// Task-list items show a checkbox instead of the bullet
+		task := ""
+		if i < len(block.Tasks) {
+			task = block.Tasks[i]
+		}
+		var marker string
+		switch task {
+		case parser.TaskChecked:
+			marker = checkedStyle.Render("[x]")
+		case parser.TaskUnchecked:
+			marker = uncheckedStyle.Render("[ ]")
+		}
+
 		var prefix string
-		if block.Ordered {
-			prefix = fmt.Sprintf("  %d. ", i+1)
-		} else {
+		switch {
+		case block.Ordered && marker != "":
+			prefix = fmt.Sprintf("  %s. %s ", listNumber(block, i), marker)
+		case block.Ordered:
+			prefix = fmt.Sprintf("  %s. ", listNumber(block, i))
+		case marker != "":
+			prefix = "  " + marker + " "
+		default:
 			prefix = "  " + bulletStyle.Render("•") + " "
 		}
 
 		itemStyle := lipgloss.NewStyle().
-			Width(r.width - 6).
+			Width(r.width - 2 - lipgloss.Width(prefix)).
 			PaddingLeft(0)
 
-		b.WriteString(prefix + itemStyle.Render(item) + "\n")
+		// Join side by side so wrapped and <br> lines stay aligned under the text
+		b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, prefix, itemStyle.Render(item)) + "\n")
 	}
 
 	return b.String()
 }
 
-func (r *Renderer) renderQuote(block parser.ContentBlock) string {
+// listNumber returns the marker of the i-th item of an ordered list, counting
+// from the list's start in its numbering style.
+func listNumber(block parser.ContentBlock, i int) string {
+	n := i + 1
+	if block.Start != 0 {
+		n = block.Start + i
+	}
+
+	// Letters and numerals only exist for positive numbers; like browsers,
+	// fall back to decimal for the rest
+	if n <= 0 {
+		return strconv.Itoa(n)
+	}
+	switch block.Numbering {
+	case parser.NumberingLowerAlpha:
+		return alphaNumber(n)
+	case parser.NumberingUpperAlpha:
+		return strings.ToUpper(alphaNumber(n))
+	case parser.NumberingLowerRoman:
+		return strings.ToLower(romanNumber(n))
+	case parser.NumberingUpperRoman:
+		return romanNumber(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// alphaNumber spells n as list letters: a to z, then aa, ab and so on.
+func alphaNumber(n int) string {
+	var b []byte
+	for ; n > 0; n = (n - 1) / 26 {
+		b = append([]byte{byte('a' + (n-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+// romanNumber spells n in upper-case Roman numerals. Past 3999, which they
+// can't express, it gives decimal.
+func romanNumber(n int) string {
+	if n >= 4000 {
+		return strconv.Itoa(n)
+	}
+	numerals := []struct {
+		value  int
+		symbol string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+		{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	}
+	var b strings.Builder
+	for _, numeral := range numerals {
+		for ; n >= numeral.value; n -= numeral.value {
+			b.WriteString(numeral.symbol)
+		}
+	}
+	return b.String()
+}
+
+// nestedQuoteGap returns how many quote levels blocks[i] and the block after
+// it share when both are parts of one nested quote, or 0 when the gap between
+// them is an ordinary one. Two top-level quotes in a row are separate quotes.
+func nestedQuoteGap(blocks []parser.ContentBlock, i int) int {
+	if i+1 >= len(blocks) || blocks[i].Type != parser.BlockQuote || blocks[i+1].Type != parser.BlockQuote {
+		return 0
+	}
+	depth, next := max(blocks[i].Level, 1), max(blocks[i+1].Level, 1)
+	if depth == 1 && next == 1 {
+		return 0
+	}
+	return min(depth, next)
+}
+
+// quoteBars returns the bars drawn to the left of a quote depth levels deep.
+func (r *Renderer) quoteBars(depth int) string {
 	barStyle := lipgloss.NewStyle().
-		Foreground(ColorQuoteLine).
+		Foreground(r.theme.QuoteLine).
 		Bold(true)
+	return strings.TrimSuffix(strings.Repeat(barStyle.Render("┃")+" ", depth), " ")
+}
+
+// renderQuote draws one bar per level of nesting down the left of the quote.
+func (r *Renderer) renderQuote(block parser.ContentBlock) string {
+	depth := max(block.Level, 1)
 
 	textStyle := lipgloss.NewStyle().
-		Foreground(ColorQuote).
+		Foreground(r.theme.Quote).
 		Italic(true).
-		Width(r.width - 8).
+		Width(r.width - 6 - 2*depth).
 		PaddingLeft(1)
 
-	bar := barStyle.Render("┃")
-	lines := strings.Split(textStyle.Render(colorizeLinks(block.Text)), "\n")
+	bar := r.quoteBars(depth)
+	inlineStyle := lipgloss.NewStyle().
+		Foreground(r.theme.Quote).
+		Italic(true)
+	lines := strings.Split(textStyle.Render(r.renderInline(block.Text, inlineStyle)), "\n")
 	var b strings.Builder
 	for _, line := range lines {
 		b.WriteString("  " + bar + " " + line + "\n")
@@ -261,53 +615,144 @@ func (r *Renderer) renderQuote(block parser.ContentBlock) string {
 }
 
 func (r *Renderer) renderImage(block parser.ContentBlock) string {
-	if block.URL != "" {
+	var data []byte
+	if block.URL != "" && !r.opts.NoImages {
+		data, _ = fetchImage(context.Background(), r.opts.Fetcher, block.URL, r.opts.NoCache)
+	}
+	return r.renderImageData(block, data)
+}
+
+// renderImageData renders already-downloaded image bytes, falling back to a
+// text placeholder when data is empty or can't be displayed.
+func (r *Renderer) renderImageData(block parser.ContentBlock, data []byte) string {
+	return r.renderNumberedImage(block, data, 0)
+}
+
+// renderNumberedImage is renderImageData for image number n of the Images
+// section, which its caption shows; 0 means the image isn't numbered.
+func (r *Renderer) renderNumberedImage(block parser.ContentBlock, data []byte, n int) string {
+	caption := block.Alt
+	if n > 0 {
+		caption = fmt.Sprintf("Image %d", n)
+		if block.Alt != "" {
+			caption += " · " + block.Alt
+		}
+	}
+
+	// Without colors (--no-color, or output that isn't a terminal) no
+	// escapes may be written: only plain ASCII art or the placeholder
+	plain := lipgloss.ColorProfile() == termenv.Ascii
+	if len(data) > 0 && plain {
+		if ascii := renderASCIIImage(data, r.width-4, false); ascii != "" {
+			return ascii + r.imageCaption(caption)
+		}
+	} else if len(data) > 0 {
 		// Try iTerm2 inline image first
 		if r.inlineImages {
-			img := renderInlineImage(block.URL, r.width-4)
-			if img != "" {
-				var b strings.Builder
-				b.WriteString("  " + img + "\n")
-				if block.Alt != "" {
-					captionStyle := lipgloss.NewStyle().
-						Foreground(ColorImage).
-						Italic(true)
-					b.WriteString(captionStyle.Render("  "+block.Alt) + "\n")
-				}
-				return b.String()
+			if img := renderInlineImage(data, r.width-4); img != "" {
+				return "  " + img + "\n" + r.imageCaption(caption)
+			}
+		}
+
+		// Then the Kitty graphics protocol
+		if r.kittyImages {
+			if img := renderKittyImage(data, r.width-4); img != "" {
+				return "  " + img + "\n" + r.imageCaption(caption)
 			}
 		}
 
-		// Fallback to ASCII art
-		ascii := renderASCIIImage(block.URL, r.width-4)
-		if ascii != "" {
-			var b strings.Builder
-			b.WriteString(ascii)
-			if block.Alt != "" {
-				captionStyle := lipgloss.NewStyle().
-					Foreground(ColorImage).
-					Italic(true)
-				b.WriteString(captionStyle.Render("  "+block.Alt) + "\n")
+		// Then sixel graphics
+		if r.sixelImages && supportsSixel() {
+			if img := renderSixelImage(data, r.width-4); img != "" {
+				return "  " + img + "\n" + r.imageCaption(caption)
 			}
-			return b.String()
+		}
+
+		// Fallback to half-block or ASCII art
+		if r.opts.ASCIIImages {
+			if ascii := renderASCIIImage(data, r.width-4, true); ascii != "" {
+				return ascii + r.imageCaption(caption)
+			}
+		} else if img := renderHalfBlockImage(data, r.width-4); img != "" {
+			return img + r.imageCaption(caption)
 		}
 	}
 
 	// Final fallback to text placeholder
-	alt := block.Alt
+	label := "IMAGE: " + block.Alt
+	switch {
+	case n > 0 && block.Alt == "":
+		label = fmt.Sprintf("IMAGE %d", n)
+	case n > 0:
+		label = fmt.Sprintf("IMAGE %d: %s", n, block.Alt)
+	case block.Alt == "":
+		label = "IMAGE: image"
+	}
+	style := lipgloss.NewStyle().
+		Foreground(r.theme.Image).
+		Italic(true)
+
+	return style.Render("  ["+label+"]") + "\n"
+}
+
+// imageCaption renders the alt text shown beneath an image, if any.
+func (r *Renderer) imageCaption(alt string) string {
 	if alt == "" {
-		alt = "image"
+		return ""
 	}
+	captionStyle := lipgloss.NewStyle().
+		Foreground(r.theme.Image).
+		Italic(true)
+	return captionStyle.Render("  "+alt) + "\n"
+}
+
+// renderEmbed renders a video, audio or other embed as a placeholder whose URL
+// is a clickable OSC 8 hyperlink, with the embed's title beneath it.
+func (r *Renderer) renderEmbed(block parser.ContentBlock) string {
 	style := lipgloss.NewStyle().
-		Foreground(ColorImage).
+		Foreground(r.theme.Image).
+		Italic(true)
+	urlStyle := lipgloss.NewStyle().
+		Foreground(r.theme.Link).
 		Italic(true)
 
-	return style.Render("  [IMAGE: "+alt+"]") + "\n"
+	label := "  [" + strings.ToUpper(block.Media) + ": "
+	shown := block.URL
+	if lipgloss.ColorProfile() != termenv.Ascii {
+		// Keep the placeholder on one line; the link still opens the full URL
+		shown = ansi.Truncate(block.URL, max(r.width-ansi.StringWidth(label)-2, 10), "…")
+	}
+	return style.Render(label) + hyperlink(block.URL, urlStyle.Render(shown)) + style.Render("]") + "\n" + r.imageCaption(block.Text)
+}
+
+// hyperlink makes text an OSC 8 hyperlink to url, clickable in terminals that
+// support it. Without colors (--no-color, or output that isn't a terminal) the
+// output is kept free of escapes and text is returned as it is.
+func hyperlink(url, text string) string {
+	if lipgloss.ColorProfile() == termenv.Ascii {
+		return text
+	}
+	return fmt.Sprintf("\033]8;;%s\033\\%s\033]8;;\033\\", url, text)
+}
+
+// embedLabel names an embed for the text exports, e.g. "Video: Intro talk".
+func embedLabel(block parser.ContentBlock) string {
+	label := "Embed"
+	switch block.Media {
+	case parser.MediaVideo:
+		label = "Video"
+	case parser.MediaAudio:
+		label = "Audio"
+	}
+	if block.Text != "" {
+		label += ": " + block.Text
+	}
+	return label
 }
 
 func (r *Renderer) renderHR() string {
 	style := lipgloss.NewStyle().
-		Foreground(ColorHR)
+		Foreground(r.theme.HR)
 
 	return style.Render("  " + strings.Repeat("━", r.width-4)) + "\n"
 }
@@ -328,12 +773,13 @@ func (r *Renderer) renderTable(block parser.ContentBlock) string {
 		return ""
 	}
 
-	// Calculate column widths based on content
+	// Calculate column widths based on content, in terminal cells so wide
+	// characters (CJK, emoji) line up
 	colWidths := make([]int, numCols)
 	for _, row := range block.Rows {
 		for j := 0; j < numCols; j++ {
-			if j < len(row) && len(row[j]) > colWidths[j] {
-				colWidths[j] = len(row[j])
+			if j < len(row) {
+				colWidths[j] = max(colWidths[j], ansi.StringWidth(row[j]))
 			}
 		}
 	}
@@ -364,9 +810,9 @@ func (r *Renderer) renderTable(block parser.ContentBlock) string {
 		}
 	}
 
-	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(ColorHeading)
-	cellStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
-	borderStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(r.theme.Heading)
+	cellStyle := lipgloss.NewStyle().Foreground(r.theme.Table)
+	borderStyle := lipgloss.NewStyle().Foreground(r.theme.Border)
 
 	var b strings.Builder
 
@@ -384,120 +830,311 @@ func (r *Renderer) renderTable(block parser.ContentBlock) string {
 		return borderStyle.Render(line.String())
 	}
 
-	// Helper to truncate/pad a cell
-	fmtCell := func(text string, width int) string {
+	// Helper to truncate/pad a cell line to a display width
+	fmtCell := func(text string, width int, align string) string {
 		if width < 1 {
 			width = 1
 		}
-		runes := []rune(text)
-		if len(runes) > width {
-			if width > 1 {
-				runes = append(runes[:width-1], '…')
-			} else {
-				runes = runes[:width]
-			}
+		if ansi.StringWidth(text) > width {
+			text = ansi.Truncate(text, width, "…")
 		}
-		text = string(runes)
-		pad := width - len(runes)
-		if pad > 0 {
-			text += strings.Repeat(" ", pad)
+		pad := width - ansi.StringWidth(text)
+		switch align {
+		case "right":
+			return strings.Repeat(" ", pad) + text
+		case "center":
+			return strings.Repeat(" ", pad/2) + text + strings.Repeat(" ", pad-pad/2)
 		}
-		return text
+		return text + strings.Repeat(" ", pad)
 	}
 
-	// Top border
-	b.WriteString("  " + hline("┌", "┬", "┐", "─") + "\n")
+	// Compact tables keep only the rules between columns and under the header
+	edge := borderStyle.Render("│")
+	if r.opts.Compact {
+		edge = ""
+	} else {
+		b.WriteString("  " + hline("┌", "┬", "┐", "─") + "\n")
+	}
 
 	for i, row := range block.Rows {
-		// Build row
-		var rowStr strings.Builder
-		rowStr.WriteString(borderStyle.Render("│"))
+		// Split each cell into the lines it takes up; a row is as tall as
+		// its tallest cell
+		cellLines := make([][]string, numCols)
+		height := 1
 		for j := 0; j < numCols; j++ {
 			cell := ""
 			if j < len(row) {
 				cell = row[j]
 			}
-			formatted := fmtCell(cell, colWidths[j])
-			if block.Header && i == 0 {
-				rowStr.WriteString(" " + headerStyle.Render(formatted) + " ")
+			if r.opts.TableTruncate {
+				cellLines[j] = []string{cell}
 			} else {
-				rowStr.WriteString(" " + cellStyle.Render(formatted) + " ")
+				cellLines[j] = strings.Split(ansi.Wrap(cell, max(colWidths[j], 1), ""), "\n")
 			}
-			rowStr.WriteString(borderStyle.Render("│"))
+			height = max(height, len(cellLines[j]))
+		}
+
+		for line := 0; line < height; line++ {
+			var rowStr strings.Builder
+			rowStr.WriteString(edge)
+			for j := 0; j < numCols; j++ {
+				text := ""
+				if line < len(cellLines[j]) {
+					text = cellLines[j][line]
+				}
+				align := ""
+				if j < len(block.Align) {
+					align = block.Align[j]
+				}
+				formatted := fmtCell(text, colWidths[j], align)
+				if block.Header && i == 0 {
+					rowStr.WriteString(" " + headerStyle.Render(formatted) + " ")
+				} else {
+					rowStr.WriteString(" " + cellStyle.Render(formatted) + " ")
+				}
+				if j < numCols-1 {
+					rowStr.WriteString(borderStyle.Render("│"))
+				}
+			}
+			rowStr.WriteString(edge)
+			b.WriteString("  " + rowStr.String() + "\n")
 		}
-		b.WriteString("  " + rowStr.String() + "\n")
 
 		// Separator after header row
 		if block.Header && i == 0 {
-			b.WriteString("  " + hline("├", "┼", "┤", "─") + "\n")
+			if r.opts.Compact {
+				b.WriteString("  " + hline("", "┼", "", "─") + "\n")
+			} else {
+				b.WriteString("  " + hline("├", "┼", "┤", "─") + "\n")
+			}
 		}
 	}
 
-	// Bottom border
-	b.WriteString("  " + hline("└", "┴", "┘", "─") + "\n")
+	if !r.opts.Compact {
+		b.WriteString("  " + hline("└", "┴", "┘", "─") + "\n")
+	}
 
 	return b.String()
 }
 
-func (r *Renderer) renderLinks(links []parser.Link) string {
+// renderLinks renders the Links section, recording in r.LinkLines which link
+// each line belongs to, given the section starts at line startLine.
+func (r *Renderer) renderLinks(links []parser.Link, startLine int) string {
 	var b strings.Builder
+	r.LinkLines = make(map[int]int)
 
-	dividerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("238"))
+	dividerStyle := lipgloss.NewStyle().Foreground(r.theme.Divider)
 	b.WriteString("\n" + dividerStyle.Render("  "+strings.Repeat("─", r.width-4)) + "\n")
 
 	headerStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(ColorMeta)
+		Foreground(r.theme.Meta)
 	b.WriteString(headerStyle.Render("  Links") + "\n\n")
 
-	idxStyle := lipgloss.NewStyle().Foreground(ColorLink).Bold(true)
-	urlStyle := lipgloss.NewStyle().Foreground(ColorLink)
-	textStyle := lipgloss.NewStyle().Foreground(ColorMeta)
+	idxStyle := lipgloss.NewStyle().Foreground(r.theme.Link).Bold(true)
+	urlStyle := lipgloss.NewStyle().Foreground(r.theme.Link)
+	textStyle := lipgloss.NewStyle().Foreground(r.theme.Meta)
+	titleStyle := textStyle.Italic(true)
 
 	for _, link := range links {
 		idx := idxStyle.Render(fmt.Sprintf("  [%d]", link.Index))
 		text := textStyle.Render(link.Text)
-		url := urlStyle.Render(link.URL)
-
-		// Use OSC 8 hyperlink if possible (clickable in supported terminals)
-		clickableURL := fmt.Sprintf("\033]8;;%s\033\\%s\033]8;;\033\\", link.URL, url)
+		if link.Title != "" && link.Title != link.Text {
+			text += textStyle.Render(" — ") + titleStyle.Render(link.Title)
+		}
+		clickableURL := hyperlink(link.URL, urlStyle.Render(link.URL))
 
+		line := startLine + strings.Count(b.String(), "\n")
+		r.LinkLines[line] = link.Index
+		r.LinkLines[line+1] = link.Index
 		b.WriteString(fmt.Sprintf("%s %s\n      %s\n", idx, text, clickableURL))
 	}
 
 	return b.String()
 }
 
-// colorizeLinks applies styling to [N] link references within text.
-func colorizeLinks(text string) string {
+// RenderLinkList renders links as "[N] text — url" lines, without the article.
+func (r *Renderer) RenderLinkList(links []parser.Link) string {
+	idxStyle := lipgloss.NewStyle().Foreground(r.theme.Link).Bold(true)
+	textStyle := lipgloss.NewStyle().Foreground(r.theme.Meta)
+	urlStyle := lipgloss.NewStyle().Foreground(r.theme.Link)
+
+	var b strings.Builder
+	for _, link := range links {
+		b.WriteString(fmt.Sprintf("%s %s %s %s\n",
+			idxStyle.Render(fmt.Sprintf("[%d]", link.Index)),
+			textStyle.Render(link.Text),
+			textStyle.Render("—"),
+			urlStyle.Render(link.URL)))
+	}
+	return b.String()
+}
+
+// renderInline applies base to text, translating inline formatting marks into
+// emphasis, code, key-cap, highlight, abbreviation and strikethrough styling,
+// and colorizing [N] link references.
+func (r *Renderer) renderInline(text string, base lipgloss.Style) string {
 	refStyle := lipgloss.NewStyle().
-		Foreground(ColorLink).
+		Foreground(r.theme.Link).
 		Bold(true)
 
 	var result strings.Builder
-	i := 0
-	for i < len(text) {
-		if text[i] == '[' {
+	var run strings.Builder
+	bold, italic, code, kbd, highlight, abbr, strike := 0, 0, 0, 0, 0, 0, 0
+
+	// flush renders the pending run of plain text with the active emphasis
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		style := base
+		if bold > 0 {
+			style = style.Bold(true)
+		}
+		if italic > 0 {
+			style = style.Italic(true)
+		}
+		if code > 0 {
+			style = style.Foreground(r.theme.Code).Background(r.theme.CodeBG)
+		}
+		if abbr > 0 {
+			style = style.Underline(true)
+		}
+		if strike > 0 {
+			style = style.Strikethrough(true)
+		}
+		if highlight > 0 {
+			style = style.Foreground(r.theme.HighlightText).Background(r.theme.Highlight)
+		}
+		if kbd > 0 {
+			// Key caps: inverted, with a space of padding on each side
+			result.WriteString(style.Reverse(true).Render(" " + run.String() + " "))
+			run.Reset()
+			return
+		}
+		result.WriteString(style.Render(run.String()))
+		run.Reset()
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case parser.MarkBoldOpen:
+			flush()
+			bold++
+			continue
+		case parser.MarkBoldClose:
+			flush()
+			bold--
+			continue
+		case parser.MarkItalicOpen:
+			flush()
+			italic++
+			continue
+		case parser.MarkItalicClose:
+			flush()
+			italic--
+			continue
+		case parser.MarkCodeOpen:
+			flush()
+			code++
+			continue
+		case parser.MarkCodeClose:
+			flush()
+			code--
+			continue
+		case parser.MarkKbdOpen:
+			flush()
+			kbd++
+			continue
+		case parser.MarkKbdClose:
+			flush()
+			kbd--
+			continue
+		case parser.MarkHighlightOpen:
+			flush()
+			highlight++
+			continue
+		case parser.MarkHighlightClose:
+			flush()
+			highlight--
+			continue
+		case parser.MarkAbbrOpen:
+			flush()
+			abbr++
+			continue
+		case parser.MarkAbbrTitle:
+			flush()
+			j := i + 1
+			for j < len(runes) && runes[j] != parser.MarkAbbrClose {
+				j++
+			}
+			if r.opts.ExpandAbbr {
+				result.WriteString(base.Foreground(r.theme.Meta).Render(" (" + string(runes[i+1:j]) + ")"))
+			}
+			i = j - 1
+			continue
+		case parser.MarkAbbrClose:
+			flush()
+			abbr--
+			continue
+		case parser.MarkStrikeOpen:
+			flush()
+			strike++
+			continue
+		case parser.MarkStrikeClose:
+			flush()
+			strike--
+			continue
+		case '[':
 			// Look for a closing bracket with only digits inside
 			j := i + 1
-			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+			for j < len(runes) && runes[j] >= '0' && runes[j] <= '9' {
 				j++
 			}
-			if j > i+1 && j < len(text) && text[j] == ']' {
-				ref := text[i : j+1]
+			if j > i+1 && j < len(runes) && runes[j] == ']' {
+				ref := string(runes[i : j+1])
+				if r.opts.SuperscriptRefs {
+					// Hang the number off the end of the link text
+					pending := run.String()
+					run.Reset()
+					run.WriteString(strings.TrimSuffix(pending, " "))
+					ref = superscript(string(runes[i+1 : j]))
+				}
+				flush()
 				result.WriteString(refStyle.Render(ref))
-				i = j + 1
+				i = j
 				continue
 			}
 		}
-		result.WriteByte(text[i])
-		i++
+		if !parser.IsMark(runes[i]) {
+			run.WriteRune(runes[i])
+		}
 	}
+	flush()
+
 	return result.String()
 }
 
+// SuperscriptDigits are the superscript forms of 0 to 9, in order, that link
+// references are written in with Options.SuperscriptRefs.
+const SuperscriptDigits = "⁰¹²³⁴⁵⁶⁷⁸⁹"
+
+// superscript writes a string of ASCII digits in superscript digits.
+func superscript(digits string) string {
+	sup := []rune(SuperscriptDigits)
+	return strings.Map(func(c rune) rune {
+		return sup[c-'0']
+	}, digits)
+}
+
 // highlightCode uses chroma to syntax-highlight code.
-func highlightCode(code, language string) string {
+func (r *Renderer) highlightCode(code, language string) string {
+	// chroma writes its own escape codes, so honor a colorless profile here
+	if lipgloss.ColorProfile() == termenv.Ascii {
+		return code
+	}
+
 	var lexer chroma.Lexer
 	if language != "" {
 		lexer = lexers.Get(language)
@@ -510,7 +1147,11 @@ func highlightCode(code, language string) string {
 	}
 	lexer = chroma.Coalesce(lexer)
 
-	style := styles.Get("monokai")
+	name := r.opts.CodeStyle
+	if name == "" {
+		name = DefaultCodeStyle
+	}
+	style := styles.Get(name)
 	if style == nil {
 		style = styles.Fallback
 	}
@@ -524,7 +1165,7 @@ func highlightCode(code, language string) string {
 	if err != nil {
 		// Fallback: return unstyled
 		codeStyle := lipgloss.NewStyle().
-			Foreground(ColorCode)
+			Foreground(r.theme.Code)
 		return codeStyle.Render(code)
 	}
 
@@ -532,7 +1173,7 @@ func highlightCode(code, language string) string {
 	err = formatter.Format(&b, style, iterator)
 	if err != nil {
 		codeStyle := lipgloss.NewStyle().
-			Foreground(ColorCode)
+			Foreground(r.theme.Code)
 		return codeStyle.Render(code)
 	}
 
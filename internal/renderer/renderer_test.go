@@ -0,0 +1,160 @@
+package renderer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+func TestRenderTableWideCharacters(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.Ascii)
+
+	block := parser.ContentBlock{
+		Type:   parser.BlockTable,
+		Header: true,
+		Rows: [][]string{
+			{"Name", "Note", "Count"},
+			{"日本語", "ok", "1"},
+			{"🎉 party", "絵文字 🍣🍣", "22"},
+			{"plain", "東京都の天気は晴れのち曇りでしょう", "333"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		width    int
+		truncate bool
+	}{
+		{"fits", 90, false},
+		{"wrapped", 36, false},
+		{"truncated", 36, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.width, Options{NoImages: true, TableTruncate: tt.truncate})
+			out := ansi.Strip(r.renderTable(block))
+			lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+			if len(lines) < 6 {
+				t.Fatalf("got %d lines, want a bordered table:\n%s", len(lines), out)
+			}
+
+			want := borderColumns(lines[0])
+			if len(want) != len(block.Rows[0])+1 {
+				t.Fatalf("top border has columns %v, want %d:\n%s", want, len(block.Rows[0])+1, out)
+			}
+			for _, line := range lines {
+				if got := borderColumns(line); !slices.Equal(got, want) {
+					t.Errorf("borders at %v, want %v in line %q:\n%s", got, want, line, out)
+				}
+			}
+		})
+	}
+}
+
+// borderColumns returns the terminal columns of the table border characters
+// in line.
+func borderColumns(line string) []int {
+	var cols []int
+	for i, r := range line {
+		if strings.ContainsRune("│┌┐└┘├┤┬┴┼", r) {
+			cols = append(cols, ansi.StringWidth(line[:i]))
+		}
+	}
+	return cols
+}
+
+func TestRenderGlamour(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.Ascii)
+
+	article := &parser.Article{
+		Title: "Intro",
+		Content: []parser.ContentBlock{
+			{Type: parser.BlockParagraph, Text: "Some text with a link [1]."},
+			{Type: parser.BlockHeading, Level: 2, Text: "Intro"},
+			{Type: parser.BlockParagraph, Text: "More text."},
+			{Type: parser.BlockHeading, Level: 2, Text: "A heading long enough that glamour has to wrap it onto a second line"},
+		},
+		Links: []parser.Link{{Index: 1, Text: "a link", URL: "https://example.com/a"}},
+	}
+	r := New(40, Options{Glamour: true})
+	out := r.RenderArticle(article)
+	lines := strings.Split(out, "\n")
+
+	if strings.Contains(out, "Links") {
+		t.Errorf("output has an empty Links heading:\n%s", out)
+	}
+	if !strings.Contains(out, "https://example.com/a") {
+		t.Errorf("output lacks the link URL:\n%s", out)
+	}
+	want := []string{"## Intro", "## A heading long enough"}
+	if len(r.HeadingLines) != len(want) {
+		t.Fatalf("HeadingLines = %v, want %d headings:\n%s", r.HeadingLines, len(want), out)
+	}
+	for i, n := range r.HeadingLines {
+		if got := strings.TrimSpace(lines[n]); !strings.HasPrefix(got, want[i]) {
+			t.Errorf("heading %d is at line %d, %q; want it to start %q", i, n, got, want[i])
+		}
+	}
+}
+
+func TestRenderImageWithoutColors(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.Ascii)
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := range 40 {
+		for y := range 20 {
+			img.Set(x, y, color.RGBA{uint8(x * 6), uint8(y * 12), 200, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	const url = "https://example.com/a.png"
+	article := &parser.Article{
+		Title:   "Pictures",
+		Content: []parser.ContentBlock{{Type: parser.BlockImage, URL: url, Alt: "a gradient"}},
+	}
+	r := New(60, Options{})
+	r.Images = map[string][]byte{url: buf.Bytes()}
+	out := r.RenderArticle(article)
+	if strings.Contains(out, "\x1b") {
+		t.Errorf("output without colors has escape sequences: %q", out)
+	}
+	if !strings.Contains(out, "a gradient") {
+		t.Errorf("output lacks the image caption:\n%s", out)
+	}
+}
+
+func TestLinksWithoutColors(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.Ascii)
+
+	const embed = "https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=a-very-long-playlist-identifier"
+	article := &parser.Article{
+		Title: "Links",
+		Content: []parser.ContentBlock{
+			{Type: parser.BlockParagraph, Text: "See the docs [1]."},
+			{Type: parser.BlockEmbed, Media: parser.MediaVideo, URL: embed, Text: "A talk"},
+		},
+		Links: []parser.Link{{Index: 1, Text: "the docs", URL: "https://example.com/docs"}},
+	}
+	out := New(40, Options{NoImages: true}).RenderArticle(article)
+	if strings.Contains(out, "\x1b") {
+		t.Errorf("output without colors has escape sequences: %q", out)
+	}
+	for _, url := range []string{embed, "https://example.com/docs"} {
+		if !strings.Contains(out, url) {
+			t.Errorf("output lacks %s:\n%s", url, out)
+		}
+	}
+}
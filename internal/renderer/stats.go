@@ -0,0 +1,163 @@
+package renderer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultWPM is the reading speed used when Options.WPM is zero.
+const DefaultWPM = 238
+
+// codeSkimFactor is how much faster than prose code listings are taken in;
+// readers skim code rather than read it word by word.
+const codeSkimFactor = 3
+
+// Stats summarizes an article's length and structure.
+type Stats struct {
+	Words          int `json:"words"`      // prose words, excluding code
+	CodeWords      int `json:"code_words"` // words in code blocks
+	ParagraphWords int `json:"-"`
+	Paragraphs     int `json:"paragraphs"`
+	Headings       int `json:"headings"`
+	Links          int `json:"links"`
+	Images         int `json:"images"`
+	CodeBlocks     int `json:"code_blocks"`
+	Tables         int `json:"tables"`
+}
+
+// ArticleStats counts the words and blocks of each kind in an article.
+func ArticleStats(article *parser.Article) Stats {
+	s := Stats{Links: len(article.Links)}
+	for _, block := range article.Content {
+		switch block.Type {
+		case parser.BlockCode:
+			s.CodeBlocks++
+			s.CodeWords += len(strings.Fields(block.Text))
+			continue
+		case parser.BlockImage:
+			s.Images++
+			continue
+		case parser.BlockHeading:
+			s.Headings++
+		case parser.BlockParagraph:
+			s.Paragraphs++
+			s.ParagraphWords += countWords(block.Text)
+		case parser.BlockTable:
+			s.Tables++
+		}
+		s.Words += countWords(block.Text)
+		for _, item := range block.Items {
+			s.Words += countWords(item)
+		}
+		for _, row := range block.Rows {
+			for _, cell := range row {
+				s.Words += countWords(cell)
+			}
+		}
+	}
+	return s
+}
+
+// WordsPerParagraph returns the average length of a paragraph in words.
+func (s Stats) WordsPerParagraph() float64 {
+	if s.Paragraphs == 0 {
+		return 0
+	}
+	return float64(s.ParagraphWords) / float64(s.Paragraphs)
+}
+
+// ReadingTime estimates how long an article takes to read. Prose and code are
+// counted separately; each time is rounded up to a whole minute.
+type ReadingTime struct {
+	Words       int `json:"words"`
+	CodeWords   int `json:"code_words"`
+	Minutes     int `json:"minutes"`
+	CodeMinutes int `json:"code_minutes"`
+}
+
+// ReadingTime estimates the reading time at wpm words per minute (DefaultWPM
+// if wpm is not positive).
+func (s Stats) ReadingTime(wpm int) ReadingTime {
+	if wpm <= 0 {
+		wpm = DefaultWPM
+	}
+	return ReadingTime{
+		Words:       s.Words,
+		CodeWords:   s.CodeWords,
+		Minutes:     ceilDiv(s.Words, wpm),
+		CodeMinutes: ceilDiv(s.CodeWords, wpm*codeSkimFactor),
+	}
+}
+
+// RenderStats renders a summary of the article's length and structure, one
+// labeled line per figure.
+func (r *Renderer) RenderStats(article *parser.Article) string {
+	labelStyle := lipgloss.NewStyle().Foreground(r.theme.Meta)
+	valueStyle := lipgloss.NewStyle().Bold(true)
+
+	s := ArticleStats(article)
+	rt := s.ReadingTime(r.opts.WPM)
+	readingTime := fmt.Sprintf("%d min", max(rt.Minutes, 1))
+	if rt.CodeMinutes > 0 {
+		readingTime += fmt.Sprintf(" (+%d min of code)", rt.CodeMinutes)
+	}
+
+	rows := []struct{ label, value string }{
+		{"Words", fmt.Sprint(s.Words)},
+		{"Reading time", readingTime},
+		{"Headings", fmt.Sprint(s.Headings)},
+		{"Paragraphs", fmt.Sprint(s.Paragraphs)},
+		{"Words/paragraph", fmt.Sprintf("%.1f", s.WordsPerParagraph())},
+		{"Links", fmt.Sprint(s.Links)},
+		{"Images", fmt.Sprint(s.Images)},
+		{"Code blocks", fmt.Sprintf("%d (%d words)", s.CodeBlocks, s.CodeWords)},
+		{"Tables", fmt.Sprint(s.Tables)},
+	}
+	var b strings.Builder
+	for _, row := range rows {
+		b.WriteString(labelStyle.Render(fmt.Sprintf("%-16s", row.label)) + " " + valueStyle.Render(row.value) + "\n")
+	}
+	return b.String()
+}
+
+// String formats the estimate for the title box, e.g.
+// "1840 words · 8 min read (+2 min of code)".
+func (rt ReadingTime) String() string {
+	s := fmt.Sprintf("%d words · %d min read", rt.Words, max(rt.Minutes, 1))
+	if rt.CodeMinutes > 0 {
+		s += fmt.Sprintf(" (+%d min of code)", rt.CodeMinutes)
+	}
+	return s
+}
+
+// countWords counts the words in block text, ignoring formatting marks and
+// [N] link references.
+func countWords(text string) int {
+	n := 0
+	for _, word := range strings.Fields(parser.StripInline(text)) {
+		if !isLinkRef(word) {
+			n++
+		}
+	}
+	return n
+}
+
+// isLinkRef reports whether word is a "[N]" link reference.
+func isLinkRef(word string) bool {
+	if len(word) < 3 || word[0] != '[' || word[len(word)-1] != ']' {
+		return false
+	}
+	for _, c := range word[1 : len(word)-1] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
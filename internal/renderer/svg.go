@@ -0,0 +1,338 @@
+package renderer
+
+import (
+	"fmt"
+	"html"
+	"image/color"
+	"strings"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Cell metrics of the SVG export, in pixels. A monospace font is about 0.6em
+// wide, so 15px text fills a 9px cell; the even line height lets half-blocks
+// split a cell exactly.
+const (
+	svgFontSize   = 15
+	svgCellWidth  = 9
+	svgLineHeight = 20
+	svgBaseline   = 15 // from the top of a line
+	svgPadding    = 20
+)
+
+const svgFont = `ui-monospace, SFMono-Regular, Menlo, Consolas, 'DejaVu Sans Mono', monospace`
+
+// svgColors are the terminal's default background and foreground behind each
+// preset theme; other themes get the dark ones.
+var svgColors = map[string][2]string{
+	"dark":      {"#1c1c1c", "#d0d0d0"},
+	"light":     {"#ffffff", "#1c1c1c"},
+	"solarized": {"#002b36", "#839496"},
+	"mono":      {"#1c1c1c", "#d0d0d0"},
+}
+
+// RenderSVG renders the article as RenderArticle would for the terminal and
+// draws the result as an SVG image, one cell per column, width columns wide.
+// Images use half-blocks, as an SVG can't hold terminal graphics. Colors come
+// through only if lipgloss's color profile has them, so callers not writing
+// to a terminal should set one.
+func (r *Renderer) RenderSVG(article *parser.Article) string {
+	r.inlineImages, r.kittyImages, r.sixelImages = false, false, false
+	return terminalSVG(r.RenderArticle(article), r.width, r.theme)
+}
+
+// svgStyle is the SGR state of a terminal cell. Colors are hex strings, ""
+// meaning the terminal's default.
+type svgStyle struct {
+	fg, bg                                          string
+	bold, faint, italic, underline, reverse, strike bool
+}
+
+// colors returns the cell's foreground and background after reverse video.
+func (s svgStyle) colors(defaultBG, defaultFG string) (fg, bg string) {
+	fg, bg = s.fg, s.bg
+	if !s.reverse {
+		return fg, bg
+	}
+	if fg == "" {
+		fg = defaultFG
+	}
+	if bg == "" {
+		bg = defaultBG
+	}
+	return bg, fg
+}
+
+// svgCell is one terminal cell. A wide character's cell is followed by an
+// empty one with width 0.
+type svgCell struct {
+	text  string
+	width int
+	style svgStyle
+}
+
+// terminalGrid replays ANSI-styled text into rows of cells. Hyperlinks and
+// other non-SGR sequences are dropped.
+func terminalGrid(s string) [][]svgCell {
+	rows := [][]svgCell{nil}
+	var style svgStyle
+	p := ansi.NewParser()
+	var state byte
+	for len(s) > 0 {
+		seq, width, n, newState := ansi.DecodeSequence(s, state, p)
+		state = newState
+		s = s[n:]
+
+		row := &rows[len(rows)-1]
+		switch {
+		case width > 0:
+			*row = append(*row, svgCell{text: seq, width: width, style: style})
+			for i := 1; i < width; i++ {
+				*row = append(*row, svgCell{style: style})
+			}
+		case seq == "\n":
+			rows = append(rows, nil)
+		case seq == "\t":
+			*row = append(*row, svgCell{text: " ", width: 1, style: style})
+			for len(*row)%8 != 0 {
+				*row = append(*row, svgCell{text: " ", width: 1, style: style})
+			}
+		case ansi.HasCsiPrefix(seq) && ansi.Cmd(p.Command()).Final() == 'm':
+			applySGR(&style, p.Params())
+		}
+	}
+	for len(rows) > 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
+
+// applySGR updates style with the parameters of an SGR sequence.
+func applySGR(style *svgStyle, params ansi.Params) {
+	if len(params) == 0 {
+		*style = svgStyle{}
+		return
+	}
+	for i := 0; i < len(params); {
+		n := 1
+		switch code := params[i].Param(0); {
+		case code == 0:
+			*style = svgStyle{}
+		case code == 1:
+			style.bold = true
+		case code == 2:
+			style.faint = true
+		case code == 3:
+			style.italic = true
+		case code == 4:
+			style.underline = true
+		case code == 7:
+			style.reverse = true
+		case code == 9:
+			style.strike = true
+		case code == 22:
+			style.bold, style.faint = false, false
+		case code == 23:
+			style.italic = false
+		case code == 24:
+			style.underline = false
+		case code == 27:
+			style.reverse = false
+		case code == 29:
+			style.strike = false
+		case code >= 30 && code <= 37:
+			style.fg = hexColor(ansi.BasicColor(code - 30))
+		case code >= 90 && code <= 97:
+			style.fg = hexColor(ansi.BasicColor(code - 90 + 8))
+		case code >= 40 && code <= 47:
+			style.bg = hexColor(ansi.BasicColor(code - 40))
+		case code >= 100 && code <= 107:
+			style.bg = hexColor(ansi.BasicColor(code - 100 + 8))
+		case code == 39:
+			style.fg = ""
+		case code == 49:
+			style.bg = ""
+		case code == 38, code == 48, code == 58:
+			var c color.Color
+			if n = ansi.ReadStyleColor(params[i:], &c); n == 0 {
+				// Malformed color; the rest can't be trusted
+				return
+			}
+			if c == nil {
+				break
+			}
+			switch code {
+			case 38:
+				style.fg = hexColor(c)
+			case 48:
+				style.bg = hexColor(c)
+			}
+		}
+		i += n
+	}
+}
+
+// hexColor formats c as #rrggbb.
+func hexColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// terminalSVG draws ANSI-styled text as an SVG image at least width columns
+// wide, on the default colors of theme.
+func terminalSVG(s string, width int, theme Theme) string {
+	rows := terminalGrid(s)
+	cols := width
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	defaults, ok := svgColors[theme.Name]
+	if !ok {
+		defaults = svgColors["dark"]
+	}
+	defaultBG, defaultFG := defaults[0], defaults[1]
+
+	w := cols*svgCellWidth + 2*svgPadding
+	h := len(rows)*svgLineHeight + 2*svgPadding
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", w, h, w, h)
+	fmt.Fprintf(&b, "<style>text { font-family: %s; font-size: %dpx; white-space: pre; }</style>\n", svgFont, svgFontSize)
+	fmt.Fprintf(&b, "<rect width=\"100%%\" height=\"100%%\" rx=\"8\" fill=\"%s\"/>\n", defaultBG)
+
+	rect := func(col, y, cells, height int, fill string) {
+		fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n",
+			svgPadding+col*svgCellWidth, y, cells*svgCellWidth, height, fill)
+	}
+
+	for y, row := range rows {
+		top := svgPadding + y*svgLineHeight
+
+		// Backgrounds, merged into one rect per run of the same color
+		for col := 0; col < len(row); {
+			_, bg := row[col].style.colors(defaultBG, defaultFG)
+			end := col + 1
+			for end < len(row) {
+				if _, next := row[end].style.colors(defaultBG, defaultFG); next != bg {
+					break
+				}
+				end++
+			}
+			if bg != "" && bg != defaultBG {
+				rect(col, top, end-col, svgLineHeight, bg)
+			}
+			col = end
+		}
+
+		// Block elements are drawn as shapes so half-block images have no
+		// gaps between lines
+		for col, cell := range row {
+			fg, _ := cell.style.colors(defaultBG, defaultFG)
+			if fg == "" {
+				fg = defaultFG
+			}
+			switch cell.text {
+			case "█":
+				rect(col, top, 1, svgLineHeight, fg)
+			case "▀":
+				rect(col, top, 1, svgLineHeight/2, fg)
+			case "▄":
+				rect(col, top+svgLineHeight/2, 1, svgLineHeight/2, fg)
+			}
+		}
+
+		b.WriteString(svgTextLine(row, top+svgBaseline, defaultBG, defaultFG))
+	}
+
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// svgTextLine returns a <text> element for the characters of row, with a
+// <tspan> placed at its column for each run of same-styled text, or "" if
+// the row has nothing to draw. A run ends after a wide character, whose
+// glyph may not be exactly two cells wide in the font.
+func svgTextLine(row []svgCell, baseline int, defaultBG, defaultFG string) string {
+	var b strings.Builder
+	for col := 0; col < len(row); {
+		start := row[col]
+		if start.width == 0 || isBlockElement(start.text) {
+			col++
+			continue
+		}
+		var text strings.Builder
+		end := col
+		for end < len(row) {
+			cell := row[end]
+			if cell.width == 0 {
+				end++
+				continue
+			}
+			if isBlockElement(cell.text) || !sameText(cell.style, start.style) {
+				break
+			}
+			text.WriteString(cell.text)
+			end++
+			if cell.width > 1 {
+				break
+			}
+		}
+		run := text.String()
+		decorated := start.style.underline || start.style.strike
+		if strings.TrimSpace(run) != "" || decorated {
+			if !decorated {
+				run = strings.TrimRight(run, " ")
+			}
+			b.WriteString(svgTSpan(run, col, start.style, defaultBG, defaultFG))
+		}
+		col = end
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("<text y=\"%d\" fill=\"%s\">%s</text>\n", baseline, defaultFG, b.String())
+}
+
+// svgTSpan returns a <tspan> drawing text at col in style.
+func svgTSpan(text string, col int, style svgStyle, defaultBG, defaultFG string) string {
+	attrs := fmt.Sprintf(" x=\"%d\"", svgPadding+col*svgCellWidth)
+	if fg, _ := style.colors(defaultBG, defaultFG); fg != "" && fg != defaultFG {
+		attrs += fmt.Sprintf(" fill=\"%s\"", fg)
+	}
+	if style.bold {
+		attrs += " font-weight=\"bold\""
+	}
+	if style.italic {
+		attrs += " font-style=\"italic\""
+	}
+	if style.faint {
+		attrs += " opacity=\"0.6\""
+	}
+	var decorations []string
+	if style.underline {
+		decorations = append(decorations, "underline")
+	}
+	if style.strike {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		attrs += fmt.Sprintf(" text-decoration=\"%s\"", strings.Join(decorations, " "))
+	}
+	return "<tspan" + attrs + ">" + html.EscapeString(text) + "</tspan>"
+}
+
+// sameText reports whether two cells' text is drawn alike. Backgrounds are
+// drawn separately, so they only matter in reverse video.
+func sameText(a, b svgStyle) bool {
+	if !a.reverse && !b.reverse {
+		a.bg, b.bg = "", ""
+	}
+	return a == b
+}
+
+// isBlockElement reports whether s is a block element terminalSVG draws as a
+// shape rather than a glyph.
+func isBlockElement(s string) bool {
+	return s == "█" || s == "▀" || s == "▄"
+}
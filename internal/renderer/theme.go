@@ -0,0 +1,197 @@
+package renderer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme groups the colors used when rendering an article. Colors are ANSI
+// color numbers or hex values like "#ff8800".
+type Theme struct {
+	Name          string
+	Heading       lipgloss.Color
+	H2            lipgloss.Color
+	H3            lipgloss.Color
+	Link          lipgloss.Color
+	LinkRef       lipgloss.Color
+	Code          lipgloss.Color
+	CodeBG        lipgloss.Color
+	Quote         lipgloss.Color
+	QuoteLine     lipgloss.Color
+	Meta          lipgloss.Color
+	Bullet        lipgloss.Color
+	Image         lipgloss.Color
+	HR            lipgloss.Color
+	Highlight     lipgloss.Color // background of <mark> text
+	HighlightText lipgloss.Color // <mark> text on Highlight
+	Divider       lipgloss.Color // rules before headings and the Images and Links sections
+	Border        lipgloss.Color // title box, code block and table borders, line numbers
+	Table         lipgloss.Color // table cell text
+}
+
+// DarkTheme is the default, for terminals with a dark background.
+var DarkTheme = Theme{
+	Name:          "dark",
+	Heading:       "205",
+	H2:            "212",
+	H3:            "218",
+	Link:          "86",
+	LinkRef:       "243",
+	Code:          "228",
+	CodeBG:        "236",
+	Quote:         "243",
+	QuoteLine:     "205",
+	Meta:          "243",
+	Bullet:        "205",
+	Image:         "243",
+	HR:            "240",
+	Highlight:     "228",
+	HighlightText: "0",
+	Divider:       "238",
+	Border:        "240",
+	Table:         "252",
+}
+
+// LightTheme uses darker foregrounds that stay readable on a white background.
+var LightTheme = Theme{
+	Name:          "light",
+	Heading:       "161",
+	H2:            "125",
+	H3:            "90",
+	Link:          "25",
+	LinkRef:       "242",
+	Code:          "130",
+	CodeBG:        "254",
+	Quote:         "241",
+	QuoteLine:     "161",
+	Meta:          "242",
+	Bullet:        "161",
+	Image:         "242",
+	HR:            "250",
+	Highlight:     "228",
+	HighlightText: "0",
+	Divider:       "252",
+	Border:        "246",
+	Table:         "236",
+}
+
+// SolarizedTheme uses the Solarized accent colors.
+var SolarizedTheme = Theme{
+	Name:          "solarized",
+	Heading:       "#d33682",
+	H2:            "#6c71c4",
+	H3:            "#268bd2",
+	Link:          "#2aa198",
+	LinkRef:       "#586e75",
+	Code:          "#b58900",
+	CodeBG:        "#073642",
+	Quote:         "#839496",
+	QuoteLine:     "#cb4b16",
+	Meta:          "#657b83",
+	Bullet:        "#cb4b16",
+	Image:         "#657b83",
+	HR:            "#586e75",
+	Highlight:     "#b58900",
+	HighlightText: "#002b36",
+	Divider:       "#073642",
+	Border:        "#586e75",
+	Table:         "#93a1a1",
+}
+
+// MonoTheme uses only shades of gray.
+var MonoTheme = Theme{
+	Name:          "mono",
+	Heading:       "255",
+	H2:            "252",
+	H3:            "250",
+	Link:          "255",
+	LinkRef:       "245",
+	Code:          "252",
+	CodeBG:        "236",
+	Quote:         "245",
+	QuoteLine:     "250",
+	Meta:          "245",
+	Bullet:        "250",
+	Image:         "245",
+	HR:            "240",
+	Highlight:     "250",
+	HighlightText: "0",
+	Divider:       "238",
+	Border:        "240",
+	Table:         "252",
+}
+
+var themes = map[string]Theme{
+	DarkTheme.Name:      DarkTheme,
+	LightTheme.Name:     LightTheme,
+	SolarizedTheme.Name: SolarizedTheme,
+	MonoTheme.Name:      MonoTheme,
+}
+
+// ThemeByName returns the preset theme with the given name.
+func ThemeByName(name string) (Theme, bool) {
+	t, ok := themes[name]
+	return t, ok
+}
+
+// ThemeNames returns the names of the preset themes, sorted.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetColor overrides one of the theme's colors by name (e.g. "heading" or
+// "code_bg"), as used in the config file's [colors] table.
+func (t *Theme) SetColor(name, value string) error {
+	colors := map[string]*lipgloss.Color{
+		"heading":        &t.Heading,
+		"h2":             &t.H2,
+		"h3":             &t.H3,
+		"link":           &t.Link,
+		"link_ref":       &t.LinkRef,
+		"code":           &t.Code,
+		"code_bg":        &t.CodeBG,
+		"quote":          &t.Quote,
+		"quote_line":     &t.QuoteLine,
+		"meta":           &t.Meta,
+		"bullet":         &t.Bullet,
+		"image":          &t.Image,
+		"hr":             &t.HR,
+		"highlight":      &t.Highlight,
+		"highlight_text": &t.HighlightText,
+		"divider":        &t.Divider,
+		"border":         &t.Border,
+		"table":          &t.Table,
+	}
+	c, ok := colors[name]
+	if !ok {
+		return fmt.Errorf("unknown color %q", name)
+	}
+	*c = lipgloss.Color(value)
+	return nil
+}
+
+// RenderThemeSample returns a heading, a paragraph with a link and inline
+// code, and a quote, drawn by the usual block renderers in r's theme, so
+// themes can be compared side by side.
+func (r *Renderer) RenderThemeSample() string {
+	code := string(parser.MarkCodeOpen) + "inline code" + string(parser.MarkCodeClose)
+	sample := []parser.ContentBlock{
+		{Type: parser.BlockHeading, Level: 1, Text: "A heading"},
+		{Type: parser.BlockParagraph, Text: "Some text with a link [1] and " + code + "."},
+		{Type: parser.BlockQuote, Text: "A quoted line."},
+	}
+	rendered := make([]string, len(sample))
+	for i, block := range sample {
+		rendered[i] = r.RenderBlock(block)
+	}
+	return strings.Join(rendered, "\n")
+}
@@ -0,0 +1,59 @@
+package renderer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/0xblz/getwebsite/internal/parser"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// RenderTOC renders the article's heading outline as a numbered, indented list.
+func (r *Renderer) RenderTOC(article *parser.Article) string {
+	var headings []parser.ContentBlock
+	minLevel := 6
+	for _, block := range article.Content {
+		if block.Type == parser.BlockHeading {
+			headings = append(headings, block)
+			if block.Level < minLevel {
+				minLevel = block.Level
+			}
+		}
+	}
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(r.theme.Heading)
+	numStyle := lipgloss.NewStyle().Foreground(r.theme.Bullet)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(article.Title) + "\n\n")
+	if len(headings) == 0 {
+		b.WriteString(lipgloss.NewStyle().Foreground(r.theme.Meta).Render("  (no headings)") + "\n")
+		return b.String()
+	}
+
+	var counters []int
+	for _, h := range headings {
+		// Never nest more than one level deeper than the previous heading,
+		// so skipped levels (h2 → h4) don't produce "1.0.1"
+		depth := h.Level - minLevel
+		if depth > len(counters) {
+			depth = len(counters)
+		}
+		if depth < len(counters) {
+			counters = counters[:depth+1]
+		} else {
+			counters = append(counters, 0)
+		}
+		counters[depth]++
+
+		parts := make([]string, len(counters))
+		for i, n := range counters {
+			parts[i] = strconv.Itoa(n)
+		}
+		num := numStyle.Render(strings.Join(parts, ".") + ".")
+		b.WriteString(fmt.Sprintf("%s%s %s\n", strings.Repeat("  ", depth+1), num, h.Text))
+	}
+
+	return b.String()
+}
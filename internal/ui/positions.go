@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// positionsPath returns $XDG_STATE_HOME/getwebsite/positions.json, falling
+// back to ~/.local/state when XDG_STATE_HOME is unset.
+func positionsPath() string {
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(base, "getwebsite", "positions.json")
+}
+
+// loadPositions reads the saved scroll offsets keyed by URL.
+func loadPositions() map[string]int {
+	positions := make(map[string]int)
+	path := positionsPath()
+	if path == "" {
+		return positions
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return positions
+	}
+	_ = json.Unmarshal(data, &positions)
+	return positions
+}
+
+// loadPosition returns the saved scroll offset for url, or 0.
+func loadPosition(url string) int {
+	return loadPositions()[url]
+}
+
+// savePosition records the scroll offset for url. Offsets at the top of the
+// page are dropped rather than stored.
+func savePosition(url string, yOffset int) {
+	path := positionsPath()
+	if path == "" || url == "" {
+		return
+	}
+	positions := loadPositions()
+	if yOffset > 0 {
+		positions[url] = yOffset
+	} else {
+		delete(positions, url)
+	}
+	data, err := json.MarshalIndent(positions, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0644)
+}
@@ -1,33 +1,48 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/0xblz/getwebsite/internal/fetcher"
+	"github.com/0xblz/getwebsite/internal/loader"
 	"github.com/0xblz/getwebsite/internal/parser"
 	"github.com/0xblz/getwebsite/internal/renderer"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
-var (
-	helpStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("243"))
-	helpKeyStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("205")).
-			Bold(true)
-	searchHighlightStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color("205")).
-				Foreground(lipgloss.Color("0")).
-				Bold(true)
-)
+// Options controls optional UI behavior.
+type Options struct {
+	Renderer renderer.Options
+	Markdown renderer.MarkdownOptions // for copying the article with Y
+	Fetcher  fetcher.Options
+	Parser   parser.Options
+	Cache    loader.CacheOptions // on-disk article cache; r reloads past it
+	Width    int                 // maximum article width; 0 means 90
+	Fresh    bool                // ignore any saved reading position
+
+	KeepTracking bool // leave utm_* and other tracking parameters on link URLs
+	NoSpinner    bool // show a static loading line instead of an animated spinner
+}
 
 // Messages
 type articleMsg struct {
@@ -35,6 +50,50 @@ type articleMsg struct {
 	err     error
 }
 
+// previewMsg carries what the page's <head> says while its body is still
+// being parsed; next waits for the rest of the load.
+type previewMsg struct {
+	preview *parser.Article
+	next    tea.Cmd
+}
+
+// imagesLoadedMsg carries the downloaded images of the article that was
+// current when loading started; gen tells stale loads apart.
+type imagesLoadedMsg struct {
+	gen    int
+	images map[string][]byte
+}
+
+// linkAction is what happens to the link chosen in the link number prompt.
+type linkAction int
+
+const (
+	linkOpen   linkAction = iota // open in the external browser
+	linkFollow                   // load inside the reader
+	linkCopy                     // copy the URL to the clipboard
+	imageSave                    // save an image of the Images section to disk
+)
+
+// linkNumberLimit caps what can be typed at the link number prompts.
+const linkNumberLimit = 10
+
+// imageSavedMsg reports where an image was saved, or why it couldn't be.
+type imageSavedMsg struct {
+	path string
+	err  error
+}
+
+// clearStatusMsg hides the footer status message.
+type clearStatusMsg struct{}
+
+// historyEntry is a previously viewed page, restored by going back.
+type historyEntry struct {
+	url     string
+	article *parser.Article
+	images  map[string][]byte
+	yOffset int
+}
+
 type Model struct {
 	// Core
 	article  *parser.Article
@@ -43,10 +102,35 @@ type Model struct {
 	width    int
 	height   int
 	url      string
+	opts     Options
+
+	// Downloaded images, keyed by URL; nil until they arrive, with placeholders
+	// shown meanwhile. imageGen is bumped whenever the article changes so a
+	// load for an earlier one is ignored.
+	images       map[string][]byte
+	imageGen     int
+	cancelImages context.CancelFunc // stops the download for the previous article
+
+	// Saved reading position to restore once content is rendered
+	pendingYOffset int
 
 	// Loading
-	loading bool
-	spinner spinner.Model
+	loading    bool
+	spinner    spinner.Model
+	loadCtx    context.Context // the current fetch; cancelled by q/esc while loading
+	cancelLoad context.CancelFunc
+	cancelled  bool            // the user gave up waiting and quit
+	err        error           // why the last load failed, shown instead of the article
+	preview    *parser.Article // title and site of the page being parsed, shown while loading
+
+	// Reloading the current page; the old scroll position is kept if the
+	// article is about the same length afterwards
+	reloading     bool
+	reloadPercent float64
+	reloadLines   int
+
+	// Article width set with the +/- keys; 0 means the default
+	widthOverride int
 
 	// Search
 	searching     bool
@@ -54,83 +138,227 @@ type Model struct {
 	searchQuery   string
 	searchMatches []int // line indices
 	searchIdx     int   // current match index
+	searchErr     string
+	caseSensitive bool // toggled with ctrl+t while typing a query
+	regexSearch   bool // toggled with ctrl+r while typing a query
+	searchOrigin  int  // scroll offset when the search began, restored by esc
 	contentLines  []string
 
 	// Section jumping
 	headingLines []int
 
+	// Link index for each line of the rendered Links section, for clicks
+	linkLines map[int]int
+
+	// Table of contents overlay
+	tocOpen bool
+	tocIdx  int // selected heading
+
+	// Help overlay listing every key, toggled with ?
+	helpOpen bool
+
 	// Open link
 	openingLink bool
+	linkAction  linkAction
 	linkInput   textinput.Model
 
+	// Pages visited by following links, most recent last
+	history []historyEntry
+
+	// Brief confirmation shown in the footer
+	status string
+
 	// Rendered content (pre-highlight)
 	rawContent string
 }
 
-func New(url string) Model {
+func New(url string, opts Options) Model {
+	// The reader's own chrome uses the article's theme too
+	if opts.Renderer.Theme.Name == "" {
+		opts.Renderer.Theme = renderer.DarkTheme
+	}
+	theme := opts.Renderer.Theme
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	s.Style = lipgloss.NewStyle().Foreground(theme.Heading)
 
 	si := textinput.New()
 	si.Prompt = "/"
-	si.PromptStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
-	si.TextStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
+	si.PromptStyle = lipgloss.NewStyle().Foreground(theme.Heading).Bold(true)
 	si.CharLimit = 100
 
 	li := textinput.New()
 	li.Prompt = "Open link #: "
-	li.PromptStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Bold(true)
-	li.TextStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
-	li.CharLimit = 10
+	li.PromptStyle = lipgloss.NewStyle().Foreground(theme.Link).Bold(true)
+	li.CharLimit = linkNumberLimit
+
+	// Ask the terminal about sixel graphics now, if it comes to that; once
+	// Bubble Tea is reading its input the reply would be taken for key presses
+	renderer.New(0, opts.Renderer).DetectImages()
 
+	// One fetcher for pages and their images, so both go through the same
+	// proxy, headers, cookies and rate limit
+	if opts.Renderer.Fetcher == nil {
+		opts.Renderer.Fetcher = fetcher.New(opts.Fetcher)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	return Model{
 		url:         url,
+		opts:        opts,
 		loading:     true,
 		spinner:     s,
+		loadCtx:     ctx,
+		cancelLoad:  cancel,
 		searchInput: si,
 		linkInput:   li,
 	}
 }
 
-func fetchArticle(url string) tea.Cmd {
-	return func() tea.Msg {
-		f := fetcher.New()
-		html, err := f.Fetch(url)
-		if err != nil {
-			return articleMsg{err: err}
+// fetchArticle loads url, giving up as soon as ctx is cancelled. A
+// previewMsg arrives once the page is fetched, ahead of the articleMsg, so
+// the title can show while a large page is parsed.
+func fetchArticle(ctx context.Context, url string, opts Options) tea.Cmd {
+	// Room for a preview per page the loader may parse, plus the article,
+	// so the load never blocks on a model that has moved on
+	msgs := make(chan tea.Msg, 4)
+	parseOpts := opts.Parser
+	parseOpts.OnPreview = func(preview *parser.Article) {
+		select {
+		case msgs <- previewMsg{preview: preview}:
+		default:
 		}
-		article, err := parser.Parse(html, url)
+	}
+
+	go func() {
+		article, err := loader.LoadCachedContext(ctx, opts.Renderer.Fetcher, url, parseOpts, opts.Cache)
 		if err != nil {
-			return articleMsg{err: err}
+			msgs <- articleMsg{err: err}
+			return
+		}
+		if !opts.KeepTracking {
+			parser.StripTrackingParams(article)
 		}
-		return articleMsg{article: article}
+		msgs <- articleMsg{article: article}
+	}()
+	return waitForLoad(msgs)
+}
+
+// waitForLoad delivers the next message of a load started by fetchArticle.
+func waitForLoad(msgs chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg := <-msgs
+		if preview, ok := msg.(previewMsg); ok {
+			preview.next = waitForLoad(msgs)
+			return preview
+		}
+		return msg
 	}
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(m.spinner.Tick, fetchArticle(m.url))
+	return tea.Batch(m.spinnerTick(), fetchArticle(m.loadCtx, m.url, m.opts))
+}
+
+// spinnerTick starts the loading spinner, unless it is turned off.
+func (m Model) spinnerTick() tea.Cmd {
+	if m.opts.NoSpinner {
+		return nil
+	}
+	return m.spinner.Tick
+}
+
+// Cancelled reports whether the user quit while a page was still loading.
+func (m Model) Cancelled() bool {
+	return m.cancelled
+}
+
+// startLoad begins fetching m.url under a fresh cancellable context.
+func (m *Model) startLoad() tea.Cmd {
+	m.loadCtx, m.cancelLoad = context.WithCancel(context.Background())
+	m.loading = true
+	opts := m.opts
+	opts.Cache.Refresh = opts.Cache.Refresh || m.reloading
+	return tea.Batch(m.spinnerTick(), fetchArticle(m.loadCtx, m.url, opts))
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
+	case previewMsg:
+		if m.loading && !m.reloading {
+			m.preview = msg.preview
+		}
+		return m, msg.next
+
 	case articleMsg:
+		m.preview = nil
+		if msg.err != nil && m.reloading {
+			// Keep showing the old copy rather than losing it
+			m.loading = false
+			m.reloading = false
+			return m, m.showStatus(fmt.Sprintf("Refresh failed: %v", msg.err))
+		}
 		if msg.err != nil {
-			// Show error and quit
+			// Shown as an error card until the user retries, goes back or quits
 			m.loading = false
-			m.ready = true
-			m.viewport.SetContent(fmt.Sprintf("Error: %v", msg.err))
+			m.article = nil
+			m.err = msg.err
 			return m, nil
 		}
+		m.err = nil
 		m.article = msg.article
 		m.loading = false
+		if !m.reloading {
+			m.images = nil
+		}
+		loadImages := m.startImageLoad()
+		m.pendingYOffset = 0
+		if !m.opts.Fresh {
+			m.pendingYOffset = loadPosition(m.url)
+		}
+		if m.reloading {
+			m.reloading = false
+			m.pendingYOffset = 0
+			if m.width > 0 {
+				m.renderContent()
+				if similarLength(len(m.contentLines), m.reloadLines) {
+					maxOffset := max(m.viewport.TotalLineCount()-m.viewport.Height, 0)
+					m.viewport.SetYOffset(int(m.reloadPercent * float64(maxOffset)))
+				} else {
+					m.viewport.GotoTop()
+				}
+			}
+			return m, tea.Batch(loadImages, m.showStatus("Refreshed"))
+		}
 		if m.width > 0 {
+			m.viewport.GotoTop()
+			m.renderContent()
+		}
+		return m, loadImages
+
+	case imagesLoadedMsg:
+		if msg.gen != m.imageGen || m.article == nil {
+			return m, nil
+		}
+		m.images = msg.images
+		if m.width > 0 && !m.loading {
 			m.renderContent()
 		}
 		return m, nil
 
+	case imageSavedMsg:
+		if msg.err != nil {
+			return m, m.showStatus(fmt.Sprintf("Save failed: %v", msg.err))
+		}
+		return m, m.showStatus("Saved " + msg.path)
+
+	case clearStatusMsg:
+		m.status = ""
+		return m, nil
+
 	case spinner.TickMsg:
 		if m.loading {
 			var cmd tea.Cmd
@@ -139,35 +367,61 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case tea.KeyMsg:
+		// Give up on a slow load
+		if m.loading {
+			switch msg.String() {
+			case "q", "esc", "ctrl+c":
+				m.cancelLoad()
+				m.cancelled = true
+				return m, tea.Quit
+			}
+		}
+
+		// Help overlay: any key closes it
+		if m.helpOpen {
+			m.helpOpen = false
+			if msg.String() == "ctrl+c" {
+				return m, m.quit()
+			}
+			return m, nil
+		}
+
 		// Search mode input
 		if m.searching {
 			switch msg.String() {
 			case "enter":
-				query := m.searchInput.Value()
-				if query != "" {
-					m.searchQuery = query
-					m.executeSearch()
-					if len(m.searchMatches) > 0 {
-						m.searchIdx = 0
-						m.jumpToMatch()
-					}
-				}
+				// The matches are already highlighted; just stop typing
 				m.searching = false
 				m.searchInput.Blur()
 				return m, nil
 			case "esc":
 				m.searching = false
 				m.searchInput.Blur()
-				// Clear search highlights
+				// Clear search highlights and go back to where the search began
 				if m.rawContent != "" {
 					m.viewport.SetContent(m.rawContent)
 				}
 				m.searchQuery = ""
 				m.searchMatches = nil
+				m.searchErr = ""
+				m.viewport.SetYOffset(m.searchOrigin)
+				return m, nil
+			case "ctrl+t":
+				m.caseSensitive = !m.caseSensitive
+				m.updateSearchPrompt()
+				m.incrementalSearch()
+				return m, nil
+			case "ctrl+r":
+				m.regexSearch = !m.regexSearch
+				m.updateSearchPrompt()
+				m.incrementalSearch()
 				return m, nil
 			default:
 				var cmd tea.Cmd
 				m.searchInput, cmd = m.searchInput.Update(msg)
+				if m.searchInput.Value() != m.searchQuery {
+					m.incrementalSearch()
+				}
 				return m, cmd
 			}
 		}
@@ -176,19 +430,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.openingLink {
 			switch msg.String() {
 			case "enter":
-				numStr := m.linkInput.Value()
-				if num, err := strconv.Atoi(numStr); err == nil && m.article != nil {
-					for _, link := range m.article.Links {
-						if link.Index == num {
-							openBrowser(link.URL)
-							break
-						}
+				numStr := strings.TrimSpace(m.linkInput.Value())
+				var cmd tea.Cmd
+				if m.linkAction == imageSave {
+					cmd = m.saveImage(numStr)
+				} else if link, ok := m.linkByNumber(numStr); ok {
+					switch m.linkAction {
+					case linkFollow:
+						cmd = m.followLink(link.URL)
+					case linkCopy:
+						cmd = m.copyLink(link)
+					default:
+						openBrowser(link.URL)
 					}
+				} else if numStr != "" {
+					cmd = m.showStatus(fmt.Sprintf("No link #%s", numStr))
 				}
 				m.openingLink = false
 				m.linkInput.Blur()
 				m.linkInput.SetValue("")
-				return m, nil
+				return m, cmd
 			case "esc":
 				m.openingLink = false
 				m.linkInput.Blur()
@@ -201,10 +462,50 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// Table of contents overlay
+		if m.tocOpen {
+			switch msg.String() {
+			case "up", "k":
+				if m.tocIdx > 0 {
+					m.tocIdx--
+				}
+			case "down", "j":
+				if m.tocIdx < len(m.headingLines)-1 {
+					m.tocIdx++
+				}
+			case "enter":
+				if m.tocIdx < len(m.headingLines) {
+					m.viewport.SetYOffset(m.headingLines[m.tocIdx])
+				}
+				m.tocOpen = false
+			case "esc", "t", "q":
+				m.tocOpen = false
+			case "ctrl+c":
+				return m, m.quit()
+			}
+			return m, nil
+		}
+
+		// Error card keys
+		if m.err != nil {
+			switch msg.String() {
+			case "q", "esc", "ctrl+c":
+				return m, m.quit()
+			case "r":
+				m.err = nil
+				return m, m.startLoad()
+			case "b", "backspace":
+				if len(m.history) > 0 {
+					return m, m.goBack()
+				}
+			}
+			return m, nil
+		}
+
 		// Normal mode keys
 		switch msg.String() {
 		case "q", "ctrl+c":
-			return m, tea.Quit
+			return m, m.quit()
 		case "esc":
 			// Clear search if active, otherwise quit
 			if m.searchQuery != "" {
@@ -215,16 +516,29 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			}
-			return m, tea.Quit
+			return m, m.quit()
 		case "g":
 			m.viewport.GotoTop()
 			return m, nil
 		case "G":
 			m.viewport.GotoBottom()
 			return m, nil
+		case "ctrl+d", "d":
+			m.viewport.HalfPageDown()
+			return m, nil
+		case "ctrl+u", "u":
+			m.viewport.HalfPageUp()
+			return m, nil
+		case "ctrl+f", "pgdown", " ":
+			m.viewport.PageDown()
+			return m, nil
+		case "ctrl+b", "pgup":
+			m.viewport.PageUp()
+			return m, nil
 		case "/":
 			if !m.loading {
 				m.searching = true
+				m.searchOrigin = m.viewport.YOffset
 				m.searchInput.SetValue("")
 				m.searchInput.Focus()
 				return m, textinput.Blink
@@ -247,17 +561,101 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "[":
 			m.jumpToPrevHeading()
 			return m, nil
-		case "o":
+		case "t":
+			if !m.loading && len(m.headingLines) > 0 {
+				m.tocOpen = true
+				m.tocIdx = m.currentHeading()
+				return m, nil
+			}
+		case "?":
+			if !m.loading && m.article != nil {
+				m.helpOpen = true
+				return m, nil
+			}
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			// Typing a link number opens the open-link prompt with it filled in
 			if !m.loading && m.article != nil && len(m.article.Links) > 0 {
 				m.openingLink = true
+				m.linkAction = linkOpen
+				m.linkInput.Prompt = "Open link #: "
+				m.linkInput.CharLimit = linkNumberLimit
+				m.linkInput.SetValue(msg.String())
+				m.linkInput.CursorEnd()
+				m.linkInput.Focus()
+				return m, textinput.Blink
+			}
+		case "o", "f", "y":
+			if !m.loading && m.article != nil && len(m.article.Links) > 0 {
+				m.openingLink = true
+				switch msg.String() {
+				case "f":
+					m.linkAction = linkFollow
+					m.linkInput.Prompt = "Follow link #: "
+				case "y":
+					m.linkAction = linkCopy
+					m.linkInput.Prompt = "Copy link #: "
+				default:
+					m.linkAction = linkOpen
+					m.linkInput.Prompt = "Open link #: "
+				}
+				m.linkInput.CharLimit = linkNumberLimit
 				m.linkInput.SetValue("")
 				m.linkInput.Focus()
 				return m, textinput.Blink
 			}
+		case "s":
+			if !m.loading && m.article != nil {
+				if _, ok := renderer.ImageURL(m.article, 1); !ok {
+					return m, m.showStatus("No images to save")
+				}
+				m.openingLink = true
+				m.linkAction = imageSave
+				m.linkInput.Prompt = "Save image # [file]: "
+				m.linkInput.CharLimit = 0 // the file name can be any length
+				m.linkInput.SetValue("")
+				m.linkInput.Focus()
+				return m, textinput.Blink
+			}
+		case "b", "backspace":
+			if !m.loading && len(m.history) > 0 {
+				return m, m.goBack()
+			}
+		case "+", "=":
+			if !m.loading && m.article != nil {
+				m.adjustWidth(widthStep)
+			}
+			return m, nil
+		case "-":
+			if !m.loading && m.article != nil {
+				m.adjustWidth(-widthStep)
+			}
+			return m, nil
+		case "Y":
+			if !m.loading && m.article != nil {
+				return m, m.copyMarkdown()
+			}
+		case "r":
+			if !m.loading && m.article != nil {
+				m.reloading = true
+				m.reloadPercent = m.viewport.ScrollPercent()
+				m.reloadLines = len(m.contentLines)
+				return m, m.startLoad()
+			}
+		}
+
+	case tea.MouseMsg:
+		// Left-clicking a [N] reference or a line of the Links section opens
+		// that link; everything else (e.g. the wheel) goes to the viewport
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft &&
+			!m.loading && m.article != nil && !m.tocOpen && !m.helpOpen && !m.searching && !m.openingLink {
+			if link, ok := m.linkAt(msg.X, msg.Y); ok {
+				openBrowser(link.URL)
+				return m, m.showStatus(fmt.Sprintf("Opened [%d] %s", link.Index, link.URL))
+			}
 		}
 
 	case tea.WindowSizeMsg:
-		headerHeight := 0
+		headerHeight := 1
 		footerHeight := 1
 		verticalMargin := headerHeight + footerHeight
 
@@ -267,6 +665,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if !m.ready {
 			m.viewport = viewport.New(msg.Width, msg.Height-verticalMargin)
 			m.viewport.YPosition = headerHeight
+			// f and b are follow/back here, not the viewport's page keys
+			m.viewport.KeyMap.PageDown = key.NewBinding(key.WithKeys("pgdown", " "))
+			m.viewport.KeyMap.PageUp = key.NewBinding(key.WithKeys("pgup"))
 			m.ready = true
 		} else {
 			m.viewport.Width = msg.Width
@@ -287,11 +688,54 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// maxWidth is the widest the article is rendered, however wide the terminal.
+func (m *Model) maxWidth() int {
+	if m.opts.Width > 0 {
+		return m.opts.Width
+	}
+	return 90
+}
+
+// Limits for the +/- width keys.
+const (
+	minArticleWidth = 40
+	widthStep       = 5
+)
+
+// articleWidth is the width the article is rendered at: the width chosen with
+// the +/- keys if any, else maxWidth, but never wider than the terminal.
+func (m *Model) articleWidth() int {
+	if m.widthOverride > 0 {
+		return min(m.widthOverride, m.width)
+	}
+	return min(m.width, m.maxWidth())
+}
+
+// adjustWidth widens or narrows the article by delta columns, staying between
+// minArticleWidth and the terminal width, and re-wraps it at the same place.
+func (m *Model) adjustWidth(delta int) {
+	width := max(min(m.articleWidth()+delta, m.width), min(minArticleWidth, m.width))
+	if width == m.articleWidth() {
+		return
+	}
+	percent := m.viewport.ScrollPercent()
+	m.widthOverride = width
+	m.renderContent()
+	maxOffset := max(m.viewport.TotalLineCount()-m.viewport.Height, 0)
+	m.viewport.SetYOffset(int(percent * float64(maxOffset)))
+}
+
 func (m *Model) renderContent() {
-	r := renderer.New(min(m.width, 90))
+	r := renderer.New(m.articleWidth(), m.opts.Renderer)
+	r.Images = m.images
+	if r.Images == nil {
+		// Not downloaded yet: show placeholders rather than wait
+		r.Images = map[string][]byte{}
+	}
 	content := r.RenderArticle(m.article)
 	m.rawContent = content
 	m.headingLines = r.HeadingLines
+	m.linkLines = r.LinkLines
 	m.contentLines = strings.Split(content, "\n")
 
 	// Re-apply search highlights if search is active
@@ -301,28 +745,117 @@ func (m *Model) renderContent() {
 	} else {
 		m.viewport.SetContent(content)
 	}
+
+	// Restore a saved position; SetYOffset clamps it if the article got shorter
+	if m.pendingYOffset > 0 {
+		m.viewport.SetYOffset(m.pendingYOffset)
+		m.pendingYOffset = 0
+	}
+}
+
+// startImageLoad returns a command that downloads the current article's
+// images in the background, so rendering (on load and on every resize) never
+// waits on the network. Until they arrive, m.images is shown as it is.
+func (m *Model) startImageLoad() tea.Cmd {
+	m.stopImageLoad()
+	if m.opts.Renderer.NoImages {
+		return nil
+	}
+	var ctx context.Context
+	ctx, m.cancelImages = context.WithCancel(context.Background())
+	article, gen, f, noCache := m.article, m.imageGen, m.opts.Renderer.Fetcher, m.opts.Renderer.NoCache
+	return func() tea.Msg {
+		return imagesLoadedMsg{gen: gen, images: renderer.FetchArticleImages(ctx, f, article, noCache)}
+	}
+}
+
+// stopImageLoad cancels any image download in progress and makes sure its
+// result is ignored if it arrives anyway.
+func (m *Model) stopImageLoad() {
+	m.imageGen++
+	if m.cancelImages != nil {
+		m.cancelImages()
+		m.cancelImages = nil
+	}
 }
 
 func (m *Model) executeSearch() {
 	m.searchMatches = nil
+	m.searchErr = ""
 	if m.searchQuery == "" {
 		return
 	}
-	query := strings.ToLower(m.searchQuery)
+	re, err := m.searchPattern()
+	if err != nil {
+		m.searchErr = "invalid pattern"
+		m.applyHighlights()
+		return
+	}
 	for i, line := range m.contentLines {
-		if strings.Contains(strings.ToLower(line), query) {
+		if re.MatchString(ansi.Strip(line)) {
 			m.searchMatches = append(m.searchMatches, i)
 		}
 	}
 	m.applyHighlights()
 }
 
+// incrementalSearch re-runs the search for the query typed so far and scrolls
+// to the first match at or below where the search began, wrapping to the top.
+// With no matches the view goes back to where it was.
+func (m *Model) incrementalSearch() {
+	m.searchQuery = m.searchInput.Value()
+	m.executeSearch()
+	if len(m.searchMatches) == 0 {
+		m.viewport.SetYOffset(m.searchOrigin)
+		return
+	}
+	m.searchIdx = 0
+	for i, line := range m.searchMatches {
+		if line >= m.searchOrigin {
+			m.searchIdx = i
+			break
+		}
+	}
+	m.jumpToMatch()
+}
+
+// searchPattern compiles the current query, treating it as a literal string
+// unless regex mode is on, and ignoring case unless case-sensitive mode is on.
+func (m *Model) searchPattern() (*regexp.Regexp, error) {
+	pattern := m.searchQuery
+	if !m.regexSearch {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !m.caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// updateSearchPrompt shows the active search modes in the search prompt.
+func (m *Model) updateSearchPrompt() {
+	prompt := "/"
+	if m.regexSearch {
+		prompt = "[.*] " + prompt
+	}
+	if m.caseSensitive {
+		prompt = "[Aa] " + prompt
+	}
+	m.searchInput.Prompt = prompt
+}
+
 func (m *Model) applyHighlights() {
 	if len(m.searchMatches) == 0 {
 		m.viewport.SetContent(m.rawContent)
 		return
 	}
 
+	re, err := m.searchPattern()
+	if err != nil {
+		m.viewport.SetContent(m.rawContent)
+		return
+	}
+
 	matchSet := make(map[int]bool)
 	for _, idx := range m.searchMatches {
 		matchSet[idx] = true
@@ -331,7 +864,7 @@ func (m *Model) applyHighlights() {
 	var highlighted strings.Builder
 	for i, line := range m.contentLines {
 		if matchSet[i] {
-			highlighted.WriteString(searchHighlightStyle.Render(line))
+			highlighted.WriteString(highlightMatches(line, re, m.selectedStyle()))
 		} else {
 			highlighted.WriteString(line)
 		}
@@ -342,6 +875,32 @@ func (m *Model) applyHighlights() {
 	m.viewport.SetContent(highlighted.String())
 }
 
+// highlightMatches applies style to each match of re within a styled line.
+// Matches are found in the visible text and cut out of the line by cell
+// position, so escape sequences are never split and the styling of the
+// surrounding text is kept.
+func highlightMatches(line string, re *regexp.Regexp, style lipgloss.Style) string {
+	plain := ansi.Strip(line)
+
+	var b strings.Builder
+	prev := 0
+	for _, loc := range re.FindAllStringIndex(plain, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		start := ansi.StringWidth(plain[:loc[0]])
+		end := start + ansi.StringWidth(plain[loc[0]:loc[1]])
+		b.WriteString(ansi.Cut(line, prev, start))
+		b.WriteString(style.Render(plain[loc[0]:loc[1]]))
+		prev = end
+	}
+	if prev == 0 {
+		return line
+	}
+	b.WriteString(ansi.Cut(line, prev, ansi.StringWidth(plain)))
+	return b.String()
+}
+
 func (m *Model) jumpToMatch() {
 	if m.searchIdx < len(m.searchMatches) {
 		line := m.searchMatches[m.searchIdx]
@@ -379,6 +938,381 @@ func (m *Model) jumpToPrevHeading() {
 	m.viewport.SetYOffset(m.headingLines[len(m.headingLines)-1])
 }
 
+// copyLink copies a link's URL to the clipboard and reports the outcome in
+// the footer for a couple of seconds.
+func (m *Model) copyLink(link parser.Link) tea.Cmd {
+	if err := copyToClipboard(link.URL); err != nil {
+		return m.showStatus(fmt.Sprintf("Copy failed: %v", err))
+	}
+	return m.showStatus(fmt.Sprintf("Copied [%d] %s", link.Index, link.URL))
+}
+
+// copyMarkdown copies the whole article as markdown to the clipboard and
+// reports the outcome in the footer.
+func (m *Model) copyMarkdown() tea.Cmd {
+	if err := copyToClipboard(renderer.RenderMarkdown(m.article, m.opts.Markdown)); err != nil {
+		return m.showStatus(fmt.Sprintf("Copy failed: %v", err))
+	}
+	return m.showStatus("Copied the article as markdown")
+}
+
+// saveImage saves an image of the Images section given "N" or "N file" from
+// the prompt. Without a file name it is named after the image URL, in the
+// current directory, without overwriting anything.
+func (m *Model) saveImage(input string) tea.Cmd {
+	numStr, path, _ := strings.Cut(input, " ")
+	n, err := strconv.Atoi(numStr)
+	if err != nil {
+		if input == "" {
+			return nil
+		}
+		return m.showStatus(fmt.Sprintf("No image #%s", numStr))
+	}
+	imageURL, ok := renderer.ImageURL(m.article, n)
+	if !ok {
+		return m.showStatus(fmt.Sprintf("No image #%d", n))
+	}
+	path = strings.TrimSpace(path)
+	if path == "" {
+		path = unusedPath(imageFileName(imageURL, n))
+	}
+
+	f, noCache := m.opts.Renderer.Fetcher, m.opts.Renderer.NoCache
+	return func() tea.Msg {
+		saved, err := renderer.SaveImage(context.Background(), f, imageURL, path, noCache)
+		return imageSavedMsg{path: saved, err: err}
+	}
+}
+
+// imageFileName names a saved image after the last segment of its URL,
+// falling back to image-N.
+func imageFileName(imageURL string, n int) string {
+	if u, err := url.Parse(imageURL); err == nil {
+		name := path.Base(u.Path)
+		if name != "." && name != "/" && !strings.HasPrefix(name, ".") {
+			return name
+		}
+	}
+	return fmt.Sprintf("image-%d", n)
+}
+
+// unusedPath returns name, or name with -2, -3 and so on before its
+// extension if a file of that name already exists.
+func unusedPath(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+// linkRefPattern matches a [N] link reference in rendered text.
+var linkRefPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// superscriptRefPattern matches a link reference rendered in superscript
+// digits, with renderer.Options.SuperscriptRefs.
+var superscriptRefPattern = regexp.MustCompile(`([` + renderer.SuperscriptDigits + `]+)`)
+
+// linkAt returns the link under the screen cell at column x, row y: a [N]
+// reference in the text, or any part of a link's entry in the Links section.
+func (m *Model) linkAt(x, y int) (parser.Link, bool) {
+	row := y - m.viewport.YPosition
+	line := m.viewport.YOffset + row
+	if row < 0 || row >= m.viewport.Height || line >= len(m.contentLines) {
+		return parser.Link{}, false
+	}
+	if idx, ok := m.linkLines[line]; ok {
+		return m.linkByNumber(strconv.Itoa(idx))
+	}
+	plain := ansi.Strip(m.contentLines[line])
+	pattern := linkRefPattern
+	if m.opts.Renderer.SuperscriptRefs {
+		pattern = superscriptRefPattern
+	}
+	for _, loc := range pattern.FindAllStringSubmatchIndex(plain, -1) {
+		start := ansi.StringWidth(plain[:loc[0]])
+		end := start + ansi.StringWidth(plain[loc[0]:loc[1]])
+		if x >= start && x < end {
+			return m.linkByNumber(normalDigits(plain[loc[2]:loc[3]]))
+		}
+	}
+	return parser.Link{}, false
+}
+
+// normalDigits turns superscript digits back into ASCII ones, leaving other
+// characters alone.
+func normalDigits(s string) string {
+	sup := []rune(renderer.SuperscriptDigits)
+	return strings.Map(func(c rune) rune {
+		if i := slices.Index(sup, c); i >= 0 {
+			return rune('0' + i)
+		}
+		return c
+	}, s)
+}
+
+// linkByNumber finds the article link whose footnote number is numStr.
+func (m *Model) linkByNumber(numStr string) (parser.Link, bool) {
+	num, err := strconv.Atoi(numStr)
+	if err != nil || m.article == nil {
+		return parser.Link{}, false
+	}
+	for _, link := range m.article.Links {
+		if link.Index == num {
+			return link, true
+		}
+	}
+	return parser.Link{}, false
+}
+
+// showStatus shows text in the footer for a couple of seconds.
+func (m *Model) showStatus(text string) tea.Cmd {
+	m.status = text
+	return tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+		return clearStatusMsg{}
+	})
+}
+
+// similarLength reports whether two line counts are within 20% of each other,
+// close enough that a scroll percentage still points at the same place.
+func similarLength(a, b int) bool {
+	return a > 0 && b > 0 && max(a, b)*5 <= min(a, b)*6
+}
+
+// quit saves the reading position of the current page and exits.
+func (m *Model) quit() tea.Cmd {
+	if m.article != nil && !m.loading {
+		savePosition(m.url, m.viewport.YOffset)
+	}
+	return tea.Quit
+}
+
+// followLink pushes the current page onto the history stack and starts
+// loading url in its place.
+func (m *Model) followLink(url string) tea.Cmd {
+	if m.article != nil {
+		savePosition(m.url, m.viewport.YOffset)
+		m.history = append(m.history, historyEntry{
+			url:     m.url,
+			article: m.article,
+			images:  m.images,
+			yOffset: m.viewport.YOffset,
+		})
+	}
+	m.url = url
+	m.clearSearch()
+	return m.startLoad()
+}
+
+// goBack restores the most recent page from the history stack, including its
+// scroll position. Its images are loaded again if they hadn't finished.
+func (m *Model) goBack() tea.Cmd {
+	prev := m.history[len(m.history)-1]
+	m.history = m.history[:len(m.history)-1]
+	m.url = prev.url
+	m.article = prev.article
+	m.images = prev.images
+	m.err = nil
+	m.clearSearch()
+	if m.width > 0 {
+		m.renderContent()
+		m.viewport.SetYOffset(prev.yOffset)
+	}
+	if m.images == nil {
+		return m.startImageLoad()
+	}
+	m.stopImageLoad()
+	return nil
+}
+
+func (m *Model) clearSearch() {
+	m.searchQuery = ""
+	m.searchMatches = nil
+	m.searchIdx = 0
+	m.searchErr = ""
+}
+
+// currentHeading returns the index of the last heading at or above the top of
+// the viewport.
+func (m *Model) currentHeading() int {
+	idx := 0
+	for i, line := range m.headingLines {
+		if line <= m.viewport.YOffset {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// headings returns the article's heading blocks in document order, matching
+// the entries of headingLines.
+func (m Model) headings() []parser.ContentBlock {
+	var headings []parser.ContentBlock
+	if m.article == nil {
+		return nil
+	}
+	for _, block := range m.article.Content {
+		if block.Type == parser.BlockHeading {
+			headings = append(headings, block)
+		}
+	}
+	return headings
+}
+
+// helpStyle is for key descriptions and other secondary text.
+func (m Model) helpStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(m.opts.Renderer.Theme.Meta)
+}
+
+// helpKeyStyle is for the keys in hints and the help overlay.
+func (m Model) helpKeyStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(m.opts.Renderer.Theme.Heading).Bold(true)
+}
+
+// selectedStyle marks the selected contents entry and search matches: the
+// heading color as a background, with the terminal's background color as the
+// text so it reads on light and dark terminals alike.
+func (m Model) selectedStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(m.opts.Renderer.Theme.Heading).Reverse(true).Bold(true)
+}
+
+func (m Model) renderTOC() string {
+	theme := m.opts.Renderer.Theme
+	titleStyle := lipgloss.NewStyle().
+		Foreground(theme.Heading).
+		Bold(true)
+	// Items are in the terminal's own text color, readable on any background
+	itemStyle := lipgloss.NewStyle()
+	selectedStyle := m.selectedStyle()
+
+	headings := m.headings()
+	minLevel := 6
+	for _, h := range headings {
+		if h.Level < minLevel {
+			minLevel = h.Level
+		}
+	}
+
+	// Keep the selection visible when there are more headings than rows
+	rows := m.viewport.Height - 4
+	if rows < 1 {
+		rows = 1
+	}
+	start := 0
+	if m.tocIdx >= rows {
+		start = m.tocIdx - rows + 1
+	}
+	end := min(start+rows, len(headings))
+
+	width := m.articleWidth() - 4
+	var lines []string
+	lines = append(lines, titleStyle.Render("Contents"), "")
+	for i := start; i < end; i++ {
+		h := headings[i]
+		text := strings.Repeat("  ", h.Level-minLevel) + h.Text
+		if width > 5 {
+			text = ansi.Truncate(text, width-4, "…")
+		}
+		if i == m.tocIdx {
+			lines = append(lines, selectedStyle.Render("▸ "+text))
+		} else {
+			lines = append(lines, itemStyle.Render("  "+text))
+		}
+	}
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.opts.Renderer.Theme.Border).
+		Padding(0, 1).
+		Width(width)
+
+	return lipgloss.Place(m.width, m.viewport.Height, lipgloss.Center, lipgloss.Top,
+		box.Render(strings.Join(lines, "\n")))
+}
+
+// helpSections lists every reader key for the help overlay.
+var helpSections = []struct {
+	title string
+	keys  []struct{ key, desc string }
+}{
+	{"Scrolling", []struct{ key, desc string }{
+		{"↓ / j", "scroll down"},
+		{"↑ / k", "scroll up"},
+		{"ctrl+d / ctrl+u", "half a page down / up"},
+		{"ctrl+f / space", "page down"},
+		{"ctrl+b / pgup", "page up"},
+		{"g / G", "jump to top / bottom"},
+	}},
+	{"Search", []struct{ key, desc string }{
+		{"/", "search as you type; enter keeps it, esc cancels"},
+		{"ctrl+t / ctrl+r", "toggle case-sensitive / regex while typing"},
+		{"n / N", "next / previous match"},
+		{"esc", "clear the search"},
+	}},
+	{"Navigation", []struct{ key, desc string }{
+		{"] / [", "next / previous section"},
+		{"t", "table of contents"},
+		{"b / backspace", "back to the previous page"},
+	}},
+	{"Links", []struct{ key, desc string }{
+		{"o", "open a link in the browser by number"},
+		{"1–9", "start typing a link number to open it"},
+		{"click", "open a [N] reference or Links entry"},
+		{"f", "follow a link inside the reader"},
+		{"y", "copy a link's URL"},
+		{"s", "save an image by its number in the Images section"},
+	}},
+	{"Page", []struct{ key, desc string }{
+		{"+ / -", "widen / narrow the text"},
+		{"Y", "copy the article as markdown"},
+		{"r", "reload, keeping your place"},
+		{"?", "this help"},
+		{"q / ctrl+c", "quit"},
+	}},
+}
+
+// renderHelp draws the help overlay: every key with what it does, grouped by
+// section in a bordered box.
+func (m Model) renderHelp() string {
+	theme := m.opts.Renderer.Theme
+	titleStyle := lipgloss.NewStyle().
+		Foreground(theme.Heading).
+		Bold(true)
+	sectionStyle := lipgloss.NewStyle().
+		Foreground(theme.Link).
+		Bold(true)
+
+	keyWidth := 0
+	for _, section := range helpSections {
+		for _, k := range section.keys {
+			keyWidth = max(keyWidth, lipgloss.Width(k.key))
+		}
+	}
+
+	width := min(max(m.width-4, 20), 70)
+	lines := []string{titleStyle.Render("Keys")}
+	for _, section := range helpSections {
+		lines = append(lines, "", sectionStyle.Render(section.title))
+		for _, k := range section.keys {
+			line := m.helpKeyStyle().Render(k.key+strings.Repeat(" ", keyWidth-lipgloss.Width(k.key))) +
+				"  " + m.helpStyle().Render(k.desc)
+			lines = append(lines, ansi.Truncate(line, width-4, "…"))
+		}
+	}
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.opts.Renderer.Theme.Border).
+		Padding(0, 1).
+		Width(width)
+
+	return lipgloss.Place(m.width, m.viewport.Height, lipgloss.Center, lipgloss.Center,
+		box.Render(strings.Join(lines, "\n")))
+}
+
 func openBrowser(url string) {
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
@@ -394,34 +1328,154 @@ func openBrowser(url string) {
 	_ = cmd.Start()
 }
 
+func copyToClipboard(text string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("pbcopy")
+	case "linux":
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmd = exec.Command("wl-copy")
+		} else {
+			cmd = exec.Command("xclip", "-selection", "clipboard")
+		}
+	case "windows":
+		cmd = exec.Command("clip.exe")
+	default:
+		return fmt.Errorf("no clipboard support on %s", runtime.GOOS)
+	}
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
 func (m Model) View() string {
 	if m.loading {
 		if !m.ready {
 			return ""
 		}
 		loadingStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("205")).
+			Foreground(m.opts.Renderer.Theme.Heading).
 			Bold(true)
 		urlStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("86"))
-		msg := m.spinner.View() + " " + loadingStyle.Render("Fetching") + " " + urlStyle.Render(m.url) + loadingStyle.Render("...")
+			Foreground(m.opts.Renderer.Theme.Link)
+		msg := loadingStyle.Render("Fetching") + " " + urlStyle.Render(m.url) + loadingStyle.Render("...") +
+			"  " + m.helpStyle().Render("(esc to cancel)")
+		if m.preview != nil {
+			msg = loadingStyle.Render("Reading the article...") + "  " + m.helpStyle().Render("(esc to cancel)")
+		}
+		if !m.opts.NoSpinner {
+			msg = m.spinner.View() + " " + msg
+		}
+		if m.preview != nil {
+			// The page is in; show its title box while the body parses
+			box := renderer.New(m.articleWidth(), m.opts.Renderer).RenderPreview(m.preview)
+			return box + "\n\n  " + msg
+		}
 		// Center vertically
 		padding := m.height / 3
 		return strings.Repeat("\n", padding) + "  " + msg
 	}
 
+	if m.err != nil {
+		return m.renderError()
+	}
+
 	if !m.ready {
 		return ""
 	}
 
+	header := m.renderHeader()
 	footer := m.renderFooter()
-	return m.viewport.View() + "\n" + footer
+	if m.helpOpen {
+		return header + "\n" + m.renderHelp() + "\n" + footer
+	}
+	if m.tocOpen {
+		return header + "\n" + m.renderTOC() + "\n" + footer
+	}
+	return header + "\n" + m.viewport.View() + "\n" + footer
+}
+
+// renderHeader draws the top status bar: the article title on the left and
+// the site name on the right, so it's clear what's being read after scrolling.
+func (m Model) renderHeader() string {
+	if m.article == nil {
+		return ""
+	}
+	titleStyle := lipgloss.NewStyle().
+		Foreground(m.opts.Renderer.Theme.Heading).
+		Bold(true)
+	siteStyle := lipgloss.NewStyle().
+		Foreground(m.opts.Renderer.Theme.Link)
+
+	site := m.article.SiteName
+	if site == "" {
+		if u, err := url.Parse(m.url); err == nil {
+			site = u.Hostname()
+		}
+	}
+	site = ansi.Truncate(site, max(m.width/3, 0), "…")
+
+	room := m.width - lipgloss.Width(site) - 2
+	if site != "" {
+		room -= 2
+	}
+	title := ansi.Truncate(m.article.Title, max(room, 0), "…")
+
+	gap := max(m.width-lipgloss.Width(title)-lipgloss.Width(site)-2, 1)
+	return " " + titleStyle.Render(title) + strings.Repeat(" ", gap) + siteStyle.Render(site)
+}
+
+// renderError draws a card explaining why the page couldn't be loaded, centered
+// in the window.
+func (m Model) renderError() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(m.opts.Renderer.Theme.Heading)
+	urlStyle := lipgloss.NewStyle().Foreground(m.opts.Renderer.Theme.Link)
+
+	title := "Couldn't load this page"
+	detail := m.err.Error()
+	var statusErr *fetcher.StatusError
+	switch {
+	case errors.As(m.err, &statusErr):
+		title = fmt.Sprintf("HTTP %d", statusErr.Code)
+		if text := http.StatusText(statusErr.Code); text != "" {
+			title += " " + text
+		}
+		detail = ""
+	case errors.Is(m.err, parser.ErrNoContent):
+		title = "No readable content found"
+		detail = ""
+	}
+
+	hints := []struct{ key, desc string }{{"r", "retry"}}
+	if len(m.history) > 0 {
+		hints = append(hints, struct{ key, desc string }{"b", "back"})
+	}
+	hints = append(hints, struct{ key, desc string }{"q", "quit"})
+	var parts []string
+	for _, k := range hints {
+		parts = append(parts, m.helpKeyStyle().Render("["+k.key+"]")+" "+m.helpStyle().Render(k.desc))
+	}
+
+	width := min(max(m.width-4, 20), 70)
+	lines := []string{titleStyle.Render(title), "", urlStyle.Render(ansi.Truncate(m.url, width-6, "…"))}
+	if detail != "" {
+		lines = append(lines, "", m.helpStyle().Width(width-6).Render(detail))
+	}
+	lines = append(lines, "", strings.Join(parts, "  "))
+
+	card := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.opts.Renderer.Theme.Border).
+		Padding(1, 2).
+		Width(width).
+		Render(strings.Join(lines, "\n"))
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, card)
 }
 
 func (m Model) renderFooter() string {
 	// Search mode footer
 	if m.searching {
-		return m.searchInput.View()
+		return m.searchInput.View() + m.searchInfo()
 	}
 
 	// Open link mode footer
@@ -429,36 +1483,66 @@ func (m Model) renderFooter() string {
 		return m.linkInput.View()
 	}
 
+	if m.helpOpen {
+		return m.helpStyle().Render("Press any key to close")
+	}
+
+	// Table of contents footer
+	if m.tocOpen {
+		tocKeys := []struct{ key, desc string }{
+			{"↑/k", "up"},
+			{"↓/j", "down"},
+			{"enter", "jump"},
+			{"esc", "close"},
+		}
+		var parts []string
+		for _, k := range tocKeys {
+			parts = append(parts,
+				m.helpKeyStyle().Render("["+k.key+"]")+" "+m.helpStyle().Render(k.desc))
+		}
+		return strings.Join(parts, "  ")
+	}
+
 	percent := fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100)
+	if m.widthOverride > 0 {
+		percent = fmt.Sprintf("%d cols  %s", m.articleWidth(), percent)
+	}
+	percentStyle := lipgloss.NewStyle().Foreground(m.opts.Renderer.Theme.Heading)
+
+	// Status message replaces the key hints while it's showing
+	if m.status != "" {
+		status := m.helpKeyStyle().Render(m.status)
+		gap := m.width - lipgloss.Width(status) - lipgloss.Width(percent) - 2
+		if gap < 1 {
+			gap = 1
+		}
+		return status + strings.Repeat(" ", gap) + percentStyle.Render(percent)
+	}
 
 	keys := []struct{ key, desc string }{
 		{"↑/k", "up"},
 		{"↓/j", "down"},
 		{"/", "search"},
-		{"n/N", "next/prev"},
 		{"]/[", "sections"},
+		{"t", "contents"},
 		{"o", "open link"},
+		{"?", "help"},
 		{"q", "quit"},
 	}
+	if len(m.history) > 0 {
+		keys = append(keys, struct{ key, desc string }{"b", "back"})
+	}
 
 	var parts []string
 	for _, k := range keys {
 		parts = append(parts,
-			helpKeyStyle.Render("["+k.key+"]")+" "+helpStyle.Render(k.desc))
+			m.helpKeyStyle().Render("["+k.key+"]")+" "+m.helpStyle().Render(k.desc))
 	}
 
 	help := strings.Join(parts, "  ")
 
 	// If search is active, show match info
-	if m.searchQuery != "" {
-		matchInfo := helpStyle.Render(fmt.Sprintf("  [%d/%d matches]", m.searchIdx+1, len(m.searchMatches)))
-		if len(m.searchMatches) == 0 {
-			matchInfo = helpStyle.Render("  [no matches]")
-		}
-		help += matchInfo
-	}
-
-	percentStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	help += m.searchInfo()
 
 	gap := m.width - lipgloss.Width(help) - lipgloss.Width(percent) - 2
 	if gap < 1 {
@@ -467,3 +1551,17 @@ func (m Model) renderFooter() string {
 
 	return help + strings.Repeat(" ", gap) + percentStyle.Render(percent)
 }
+
+// searchInfo describes the current search's matches for the footer, or is
+// empty when there's no search.
+func (m Model) searchInfo() string {
+	switch {
+	case m.searchQuery == "":
+		return ""
+	case m.searchErr != "":
+		return m.helpStyle().Render("  [" + m.searchErr + "]")
+	case len(m.searchMatches) == 0:
+		return m.helpStyle().Render("  [no matches]")
+	}
+	return m.helpStyle().Render(fmt.Sprintf("  [%d/%d matches]", m.searchIdx+1, len(m.searchMatches)))
+}